@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	AssertionStatusCode = "statusCode"
+	AssertionHeader     = "header"
+	AssertionJSONPath   = "jsonPath"
+	AssertionRegex      = "regex"
+	AssertionBody       = "body"
+	AssertionBodySize   = "bodySize"
+)
+
+const (
+	OperatorEquals      = "equals"
+	OperatorNotEquals   = "notEquals"
+	OperatorContains    = "contains"
+	OperatorExists      = "exists"
+	OperatorNotExists   = "notExists"
+	OperatorMatches     = "matches"
+	OperatorNotMatches  = "notMatches"
+	OperatorIn          = "in"
+	OperatorNotIn       = "notIn"
+	OperatorLessThan    = "lessThan"
+	OperatorGreaterThan = "greaterThan"
+
+	OperatorLessThanOrEqual    = "lessThanOrEqual"
+	OperatorGreaterThanOrEqual = "greaterThanOrEqual"
+)
+
+type Assertion struct {
+	Type     string `bson:"type"`
+	Target   string `bson:"target,omitempty"` // header name or JSONPath expression
+	Operator string `bson:"operator,omitempty"`
+	Value    string `bson:"value,omitempty"`
+}
+
+type AssertionResult struct {
+	Type     string `bson:"type"`
+	Target   string `bson:"target,omitempty"`
+	Operator string `bson:"operator"`
+	Expected string `bson:"expected,omitempty"`
+	Actual   string `bson:"actual,omitempty"`
+	Passed   bool   `bson:"passed"`
+	Message  string `bson:"message,omitempty"`
+}
+
+type ResponseSnapshot struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	TLS        *tls.ConnectionState
+	Timings    *RequestTimings
+}
+
+func EvaluateAssertions(assertions []Assertion, resp ResponseSnapshot) []AssertionResult {
+	results := make([]AssertionResult, 0, len(assertions))
+
+	// The body is decoded at most once, and only if a JSONPath assertion needs it
+	var (
+		document    interface{}
+		documentErr error
+		decoded     bool
+	)
+
+	for _, assertion := range assertions {
+		var result AssertionResult
+
+		switch assertion.Type {
+		case AssertionStatusCode:
+			result = evaluateStatusCode(assertion, resp.StatusCode)
+		case AssertionHeader:
+			result = evaluateHeader(assertion, resp.Header)
+		case AssertionJSONPath:
+			if !decoded {
+				document, documentErr = decodeJSONBody(resp.Body)
+				decoded = true
+			}
+			result = evaluateJSONPath(assertion, document, documentErr)
+		case AssertionRegex:
+			result = evaluateRegex(assertion, resp.Body)
+		case AssertionBody:
+			result = evaluateBody(assertion, resp.Body)
+		case AssertionBodySize:
+			result = evaluateBodySize(assertion, len(resp.Body))
+		default:
+			result = AssertionResult{
+				Type:     assertion.Type,
+				Target:   assertion.Target,
+				Operator: assertion.Operator,
+				Expected: assertion.Value,
+				Message:  fmt.Sprintf("unknown assertion type '%s'", assertion.Type),
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func AssertionsPassed(results []AssertionResult) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func DescribeFailedAssertions(results []AssertionResult) string {
+	var failures []string
+	for _, result := range results {
+		if result.Passed {
+			continue
+		}
+		description := result.Type
+		if result.Target != "" {
+			description += " " + result.Target
+		}
+		if result.Message != "" {
+			description += ": " + result.Message
+		}
+		failures = append(failures, description)
+	}
+	return strings.Join(failures, "; ")
+}
+
+func newAssertionResult(assertion Assertion, defaultOperator string) AssertionResult {
+	operator := assertion.Operator
+	if operator == "" {
+		operator = defaultOperator
+	}
+	return AssertionResult{
+		Type:     assertion.Type,
+		Target:   assertion.Target,
+		Operator: operator,
+		Expected: assertion.Value,
+	}
+}
+
+func evaluateStatusCode(assertion Assertion, statusCode int) AssertionResult {
+	result := newAssertionResult(assertion, OperatorIn)
+	result.Actual = strconv.Itoa(statusCode)
+
+	matches, err := statusCodeMatches(assertion.Value, statusCode)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	switch result.Operator {
+	case OperatorIn, OperatorEquals:
+		result.Passed = matches
+	case OperatorNotIn, OperatorNotEquals:
+		result.Passed = !matches
+	default:
+		result.Message = fmt.Sprintf("unsupported operator '%s' for status code", result.Operator)
+		return result
+	}
+
+	if !result.Passed {
+		result.Message = fmt.Sprintf("status code %d does not satisfy %s %s", statusCode, result.Operator, assertion.Value)
+	}
+	return result
+}
+
+// statusCodeMatches accepts a comma separated list of codes ("200"),
+// classes ("2xx") and inclusive ranges ("200-299").
+func statusCodeMatches(spec string, statusCode int) (bool, error) {
+	if strings.TrimSpace(spec) == "" {
+		return false, fmt.Errorf("empty status code list")
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+
+		switch {
+		case len(part) == 3 && strings.HasSuffix(part, "xx"):
+			class, err := strconv.Atoi(part[:1])
+			if err != nil {
+				return false, fmt.Errorf("invalid status class '%s'", part)
+			}
+			if statusCode/100 == class {
+				return true, nil
+			}
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			low, errLow := strconv.Atoi(strings.TrimSpace(bounds[0]))
+			high, errHigh := strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if errLow != nil || errHigh != nil {
+				return false, fmt.Errorf("invalid status range '%s'", part)
+			}
+			if statusCode >= low && statusCode <= high {
+				return true, nil
+			}
+		default:
+			code, err := strconv.Atoi(part)
+			if err != nil {
+				return false, fmt.Errorf("invalid status code '%s'", part)
+			}
+			if statusCode == code {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func evaluateHeader(assertion Assertion, header http.Header) AssertionResult {
+	defaultOperator := OperatorEquals
+	if assertion.Value == "" {
+		defaultOperator = OperatorExists
+	}
+	result := newAssertionResult(assertion, defaultOperator)
+
+	values, found := header[http.CanonicalHeaderKey(assertion.Target)]
+	actual := strings.Join(values, ", ")
+	if found {
+		result.Actual = actual
+	}
+
+	passed, err := compareValue(result.Operator, actual, found, assertion.Value)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	result.Passed = passed
+	if !passed {
+		if !found && result.Operator != OperatorNotExists {
+			result.Message = fmt.Sprintf("header '%s' not present", assertion.Target)
+		} else {
+			result.Message = fmt.Sprintf("header '%s' does not satisfy %s", assertion.Target, result.Operator)
+		}
+	}
+	return result
+}
+
+func evaluateJSONPath(assertion Assertion, document interface{}, documentErr error) AssertionResult {
+	defaultOperator := OperatorEquals
+	if assertion.Value == "" {
+		defaultOperator = OperatorExists
+	}
+	result := newAssertionResult(assertion, defaultOperator)
+
+	if documentErr != nil {
+		result.Message = fmt.Sprintf("body is not valid JSON: %v", documentErr)
+		return result
+	}
+
+	value, found, err := LookupJSONPath(document, assertion.Target)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	actual := ""
+	if found {
+		actual = FormatJSONValue(value)
+		result.Actual = actual
+	}
+
+	var passed bool
+	if items, isArray := value.([]interface{}); isArray && result.Operator == OperatorContains {
+		for _, item := range items {
+			if FormatJSONValue(item) == assertion.Value {
+				passed = true
+				break
+			}
+		}
+	} else {
+		passed, err = compareValue(result.Operator, actual, found, assertion.Value)
+		if err != nil {
+			result.Message = err.Error()
+			return result
+		}
+	}
+
+	result.Passed = passed
+	if !passed {
+		if !found && result.Operator != OperatorNotExists {
+			result.Message = fmt.Sprintf("path '%s' not found", assertion.Target)
+		} else {
+			result.Message = fmt.Sprintf("value at '%s' does not satisfy %s", assertion.Target, result.Operator)
+		}
+	}
+	return result
+}
+
+func evaluateRegex(assertion Assertion, body []byte) AssertionResult {
+	result := newAssertionResult(assertion, OperatorMatches)
+
+	pattern, err := regexp.Compile(assertion.Value)
+	if err != nil {
+		result.Message = fmt.Sprintf("invalid regex: %v", err)
+		return result
+	}
+
+	matched := pattern.Match(body)
+	switch result.Operator {
+	case OperatorMatches:
+		result.Passed = matched
+	case OperatorNotMatches:
+		result.Passed = !matched
+	default:
+		result.Message = fmt.Sprintf("unsupported operator '%s' for regex", result.Operator)
+		return result
+	}
+
+	if !result.Passed {
+		result.Message = fmt.Sprintf("body does not satisfy %s %s", result.Operator, assertion.Value)
+	}
+	return result
+}
+
+func evaluateBody(assertion Assertion, body []byte) AssertionResult {
+	result := newAssertionResult(assertion, OperatorEquals)
+
+	expected := strings.TrimSpace(assertion.Value)
+	actual := strings.TrimSpace(string(body))
+
+	passed, err := compareValue(result.Operator, actual, true, expected)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	result.Passed = passed
+	if !passed {
+		result.Message = fmt.Sprintf("body does not satisfy %s", result.Operator)
+	}
+	return result
+}
+
+func evaluateBodySize(assertion Assertion, size int) AssertionResult {
+	result := newAssertionResult(assertion, OperatorLessThan)
+	result.Actual = strconv.Itoa(size)
+
+	passed, err := compareValue(result.Operator, result.Actual, true, assertion.Value)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	result.Passed = passed
+	if !passed {
+		result.Message = fmt.Sprintf("body size %d bytes does not satisfy %s %s", size, result.Operator, assertion.Value)
+	}
+	return result
+}
+
+func compareValue(operator, actual string, found bool, expected string) (bool, error) {
+	switch operator {
+	case OperatorExists:
+		return found, nil
+	case OperatorNotExists:
+		return !found, nil
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	switch operator {
+	case OperatorEquals:
+		return actual == expected, nil
+	case OperatorNotEquals:
+		return actual != expected, nil
+	case OperatorContains:
+		return strings.Contains(actual, expected), nil
+	case OperatorMatches, OperatorNotMatches:
+		pattern, err := regexp.Compile(expected)
+		if err != nil {
+			return false, fmt.Errorf("invalid regex: %v", err)
+		}
+		return pattern.MatchString(actual) == (operator == OperatorMatches), nil
+	case OperatorLessThan, OperatorGreaterThan, OperatorLessThanOrEqual, OperatorGreaterThanOrEqual:
+		actualNumber, err := strconv.ParseFloat(actual, 64)
+		if err != nil {
+			return false, fmt.Errorf("value '%s' is not a number", actual)
+		}
+		expectedNumber, err := strconv.ParseFloat(expected, 64)
+		if err != nil {
+			return false, fmt.Errorf("expected value '%s' is not a number", expected)
+		}
+		switch operator {
+		case OperatorLessThan:
+			return actualNumber < expectedNumber, nil
+		case OperatorLessThanOrEqual:
+			return actualNumber <= expectedNumber, nil
+		case OperatorGreaterThanOrEqual:
+			return actualNumber >= expectedNumber, nil
+		}
+		return actualNumber > expectedNumber, nil
+	default:
+		return false, fmt.Errorf("unsupported operator '%s'", operator)
+	}
+}
+
+func decodeJSONBody(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// LookupJSONPath resolves a JSONPath subset: $.a.b, $.items[0].id and $['key'].
+func LookupJSONPath(document interface{}, path string) (interface{}, bool, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, false, fmt.Errorf("empty JSONPath")
+	}
+	if !strings.HasPrefix(path, "$") {
+		path = "$." + path
+	}
+
+	current := document
+	rest := path[1:]
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			if key == "" {
+				return nil, false, fmt.Errorf("invalid JSONPath '%s'", path)
+			}
+
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false, nil
+			}
+			if current, ok = object[key]; !ok {
+				return nil, false, nil
+			}
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, false, fmt.Errorf("invalid JSONPath '%s': unclosed bracket", path)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				object, ok := current.(map[string]interface{})
+				if !ok {
+					return nil, false, nil
+				}
+				if current, ok = object[selector[1:len(selector)-1]]; !ok {
+					return nil, false, nil
+				}
+				continue
+			}
+
+			index, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid JSONPath '%s': bad index '%s'", path, selector)
+			}
+			items, ok := current.([]interface{})
+			if !ok {
+				return nil, false, nil
+			}
+			if index < 0 {
+				index += len(items)
+			}
+			if index < 0 || index >= len(items) {
+				return nil, false, nil
+			}
+			current = items[index]
+		default:
+			return nil, false, fmt.Errorf("invalid JSONPath '%s'", path)
+		}
+	}
+
+	return current, true, nil
+}
+
+func FormatJSONValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(encoded)
+	}
+}
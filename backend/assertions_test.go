@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestEvaluateAssertions(t *testing.T) {
+	resp := ResponseSnapshot{
+		StatusCode: 201,
+		Header:     http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:       []byte(`{"status":"ok","version":"1.4.2","count":12,"items":[{"id":"a1"},{"id":"b2"}],"tags":["eu","prod"],"weird key":true,"none":null}`),
+	}
+
+	tests := []struct {
+		name      string
+		assertion Assertion
+		passed    bool
+		actual    string
+	}{
+		{"status in list", Assertion{Type: AssertionStatusCode, Value: "200,201"}, true, "201"},
+		{"status class", Assertion{Type: AssertionStatusCode, Value: "2xx"}, true, "201"},
+		{"status range", Assertion{Type: AssertionStatusCode, Value: "300-399"}, false, "201"},
+		{"status not in", Assertion{Type: AssertionStatusCode, Operator: OperatorNotIn, Value: "5xx"}, true, "201"},
+		{"status bad spec", Assertion{Type: AssertionStatusCode, Value: "2x"}, false, "201"},
+		{"header exists", Assertion{Type: AssertionHeader, Target: "content-type"}, true, "application/json; charset=utf-8"},
+		{"header contains", Assertion{Type: AssertionHeader, Target: "Content-Type", Operator: OperatorContains, Value: "json"}, true, "application/json; charset=utf-8"},
+		{"header missing", Assertion{Type: AssertionHeader, Target: "X-Request-Id"}, false, ""},
+		{"header not exists", Assertion{Type: AssertionHeader, Target: "X-Request-Id", Operator: OperatorNotExists}, true, ""},
+		{"json equals", Assertion{Type: AssertionJSONPath, Target: "$.status", Value: "ok"}, true, "ok"},
+		{"json without root", Assertion{Type: AssertionJSONPath, Target: "status", Value: "ok"}, true, "ok"},
+		{"json index", Assertion{Type: AssertionJSONPath, Target: "$.items[1].id", Value: "b2"}, true, "b2"},
+		{"json negative index", Assertion{Type: AssertionJSONPath, Target: "$.items[-1].id", Value: "b2"}, true, "b2"},
+		{"json quoted key", Assertion{Type: AssertionJSONPath, Target: "$['weird key']", Value: "true"}, true, "true"},
+		{"json null", Assertion{Type: AssertionJSONPath, Target: "$.none", Value: "null"}, true, "null"},
+		{"json number", Assertion{Type: AssertionJSONPath, Target: "$.count", Operator: OperatorGreaterThan, Value: "10"}, true, "12"},
+		{"json number not greater", Assertion{Type: AssertionJSONPath, Target: "$.count", Operator: OperatorGreaterThan, Value: "12"}, false, "12"},
+		{"json number greater or equal", Assertion{Type: AssertionJSONPath, Target: "$.count", Operator: OperatorGreaterThanOrEqual, Value: "12"}, true, "12"},
+		{"json number less or equal", Assertion{Type: AssertionJSONPath, Target: "$.count", Operator: OperatorLessThanOrEqual, Value: "11.5"}, false, "12"},
+		{"json array contains", Assertion{Type: AssertionJSONPath, Target: "$.tags", Operator: OperatorContains, Value: "prod"}, true, `["eu","prod"]`},
+		{"json matches", Assertion{Type: AssertionJSONPath, Target: "$.version", Operator: OperatorMatches, Value: `^1\.\d+\.\d+$`}, true, "1.4.2"},
+		{"json out of range", Assertion{Type: AssertionJSONPath, Target: "$.items[5].id"}, false, ""},
+		{"json not exists", Assertion{Type: AssertionJSONPath, Target: "$.error", Operator: OperatorNotExists}, true, ""},
+		{"json unclosed bracket", Assertion{Type: AssertionJSONPath, Target: "$.items[0"}, false, ""},
+		{"regex", Assertion{Type: AssertionRegex, Value: `"id":"b\d"`}, true, ""},
+		{"regex not matches", Assertion{Type: AssertionRegex, Operator: OperatorNotMatches, Value: "error"}, true, ""},
+		{"regex invalid", Assertion{Type: AssertionRegex, Value: "(unclosed"}, false, ""},
+		{"body contains", Assertion{Type: AssertionBody, Operator: OperatorContains, Value: `"status":"ok"`}, true, ""},
+		{"body equals", Assertion{Type: AssertionBody, Value: "ok"}, false, ""},
+		{"body size", Assertion{Type: AssertionBodySize, Value: "1024"}, true, "128"},
+		{"body size too large", Assertion{Type: AssertionBodySize, Value: "100"}, false, "128"},
+		{"unknown type", Assertion{Type: "latency"}, false, ""},
+		{"unknown operator", Assertion{Type: AssertionHeader, Target: "Content-Type", Operator: "startsWith", Value: "app"}, false, "application/json; charset=utf-8"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results := EvaluateAssertions([]Assertion{test.assertion}, resp)
+			if len(results) != 1 {
+				t.Fatalf("results = %+v", results)
+			}
+			result := results[0]
+			if result.Passed != test.passed || result.Actual != test.actual {
+				t.Errorf("result = %+v", result)
+			}
+			if result.Passed == (result.Message != "") {
+				t.Errorf("message = %q for passed = %v", result.Message, result.Passed)
+			}
+		})
+	}
+}
+
+func TestEvaluateAssertionsInvalidJSON(t *testing.T) {
+	assertions := []Assertion{
+		{Type: AssertionJSONPath, Target: "$.status", Value: "ok"},
+		{Type: AssertionJSONPath, Target: "$.version"},
+		{Type: AssertionStatusCode, Value: "200"},
+	}
+
+	results := EvaluateAssertions(assertions, ResponseSnapshot{StatusCode: 200, Body: []byte("<html>")})
+
+	if results[0].Passed || results[1].Passed || !results[2].Passed {
+		t.Fatalf("results = %+v", results)
+	}
+	if AssertionsPassed(results) {
+		t.Error("assertions passed on a body that is not JSON")
+	}
+	if got := DescribeFailedAssertions(results); got != "jsonPath $.status: body is not valid JSON: invalid character '<' looking for beginning of value; jsonPath $.version: body is not valid JSON: invalid character '<' looking for beginning of value" {
+		t.Errorf("description = %q", got)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthOAuth2 = "oauth2"
+
+	// Tokens are refreshed this long before they expire
+	tokenExpiryMargin    = 30 * time.Second
+	defaultTokenLifetime = 5 * time.Minute
+	maxTokenResponseSize = 1 << 20
+)
+
+type AuthConfig struct {
+	Type string `bson:"type" json:"type"` // basic, bearer or oauth2
+
+	Username string `bson:"username,omitempty" json:"username,omitempty"`
+	Password string `bson:"password,omitempty" json:"password,omitempty"`
+
+	Token string `bson:"token,omitempty" json:"token,omitempty"`
+
+	// OAuth2 client credentials grant
+	TokenURL        string   `bson:"tokenUrl,omitempty" json:"tokenUrl,omitempty"`
+	ClientID        string   `bson:"clientId,omitempty" json:"clientId,omitempty"`
+	ClientSecret    string   `bson:"clientSecret,omitempty" json:"clientSecret,omitempty"`
+	Scopes          []string `bson:"scopes,omitempty" json:"scopes,omitempty"`
+	Audience        string   `bson:"audience,omitempty" json:"audience,omitempty"`
+	ClientAuthStyle string   `bson:"clientAuthStyle,omitempty" json:"clientAuthStyle,omitempty"` // header (default) or body
+}
+
+func (a AuthConfig) Validate() error {
+	switch a.Type {
+	case AuthBasic:
+		if a.Username == "" {
+			return fmt.Errorf("basic auth needs a username")
+		}
+	case AuthBearer:
+		if a.Token == "" {
+			return fmt.Errorf("bearer auth needs a token")
+		}
+	case AuthOAuth2:
+		if a.TokenURL == "" || a.ClientID == "" {
+			return fmt.Errorf("oauth2 auth needs tokenUrl and clientId")
+		}
+	default:
+		return fmt.Errorf("unknown auth type '%s'", a.Type)
+	}
+	return nil
+}
+
+type oauth2Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type cachedToken struct {
+	mu      sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// Authenticator applies auth blocks to outgoing requests and keeps OAuth2
+// tokens until shortly before they expire.
+type Authenticator struct {
+	mu     sync.Mutex
+	tokens map[string]*cachedToken
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		tokens: make(map[string]*cachedToken),
+	}
+}
+
+// Apply sets the Authorization header; client is used to fetch OAuth2 tokens
+func (a *Authenticator) Apply(ctx context.Context, client *http.Client, req *http.Request, auth *AuthConfig) error {
+	header, err := a.Header(ctx, client, auth)
+	if err != nil {
+		return err
+	}
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	return nil
+}
+
+// Header returns the Authorization value for auth, empty without auth. It
+// is also sent as grpc metadata.
+func (a *Authenticator) Header(ctx context.Context, client *http.Client, auth *AuthConfig) (string, error) {
+	if auth == nil {
+		return "", nil
+	}
+
+	if err := auth.Validate(); err != nil {
+		return "", err
+	}
+
+	switch auth.Type {
+	case AuthBasic:
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password)), nil
+	case AuthBearer:
+		return "Bearer " + auth.Token, nil
+	case AuthOAuth2:
+		token, err := a.token(ctx, client, *auth)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+
+	return "", nil
+}
+
+// Invalidate drops a cached OAuth2 token, e.g. after the API answered 401
+func (a *Authenticator) Invalidate(auth *AuthConfig) {
+	if auth == nil || auth.Type != AuthOAuth2 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.tokens, tokenKey(*auth))
+}
+
+func (a *Authenticator) token(ctx context.Context, client *http.Client, auth AuthConfig) (string, error) {
+	key := tokenKey(auth)
+
+	a.mu.Lock()
+	cached, ok := a.tokens[key]
+	if !ok {
+		cached = &cachedToken{}
+		a.tokens[key] = cached
+	}
+	a.mu.Unlock()
+
+	// Concurrent requests wait for a single fetch
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+
+	if cached.value != "" && time.Now().Add(tokenExpiryMargin).Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	token, err := fetchClientCredentialsToken(ctx, client, auth)
+	if err != nil {
+		return "", err
+	}
+
+	lifetime := defaultTokenLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+
+	cached.value = token.AccessToken
+	cached.expires = time.Now().Add(lifetime)
+
+	return cached.value, nil
+}
+
+func fetchClientCredentialsToken(ctx context.Context, client *http.Client, auth AuthConfig) (*oauth2Token, error) {
+	if err := auth.Validate(); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+	if auth.Audience != "" {
+		form.Set("audience", auth.Audience)
+	}
+	if auth.ClientAuthStyle == "body" {
+		form.Set("client_id", auth.ClientID)
+		form.Set("client_secret", auth.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("error creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if auth.ClientAuthStyle != "body" {
+		req.SetBasicAuth(url.QueryEscape(auth.ClientID), url.QueryEscape(auth.ClientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching oauth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading oauth2 token response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("error fetching oauth2 token: %w", &HTTPStatusError{StatusCode: resp.StatusCode, Body: truncate(string(body), 200)})
+	}
+
+	var token oauth2Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("error decoding oauth2 token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("oauth2 token response has no access_token")
+	}
+
+	return &token, nil
+}
+
+// tokenKey hashes the client secret so it is not kept as a map key
+func tokenKey(auth AuthConfig) string {
+	secret := sha256.Sum256([]byte(auth.ClientSecret))
+	return fmt.Sprintf("%s|%s|%x|%s|%s|%s", auth.TokenURL, auth.ClientID, secret,
+		strings.Join(auth.Scopes, " "), auth.Audience, auth.ClientAuthStyle)
+}
+
+func authType(auth *AuthConfig) string {
+	if auth == nil {
+		return ""
+	}
+	return auth.Type
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuthenticatorApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		auth   *AuthConfig
+		header string
+		err    string
+	}{
+		{"none", nil, "", ""},
+		{"basic", &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "s3cret"}, "Basic bW9uaXRvcjpzM2NyZXQ=", ""},
+		{"bearer", &AuthConfig{Type: AuthBearer, Token: "t0k3n"}, "Bearer t0k3n", ""},
+		{"basic without username", &AuthConfig{Type: AuthBasic, Password: "s3cret"}, "", "basic auth needs a username"},
+		{"bearer without token", &AuthConfig{Type: AuthBearer}, "", "bearer auth needs a token"},
+		{"oauth2 without client", &AuthConfig{Type: AuthOAuth2, TokenURL: "http://127.0.0.1:1/token"}, "", "oauth2 auth needs tokenUrl and clientId"},
+		{"unknown", &AuthConfig{Type: "digest"}, "", "unknown auth type 'digest'"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "http://api.example.com", nil)
+		err := NewAuthenticator().Apply(context.Background(), http.DefaultClient, req, test.auth)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: err = %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil || req.Header.Get("Authorization") != test.header {
+			t.Errorf("%s: Authorization = %q, err = %v", test.name, req.Header.Get("Authorization"), err)
+		}
+	}
+}
+
+// tokenServer issues numbered tokens and records the last token request
+type tokenServer struct {
+	*httptest.Server
+	issued   atomic.Int32
+	lastForm string
+	lastAuth string
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	t.Helper()
+
+	server := &tokenServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		server.lastForm = r.PostForm.Encode()
+		server.lastAuth = r.Header.Get("Authorization")
+		n := server.issued.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOAuth2TokenCaching(t *testing.T) {
+	server := newTokenServer(t, 3600)
+	auth := &AuthConfig{
+		Type:         AuthOAuth2,
+		TokenURL:     server.URL,
+		ClientID:     "monitor",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"health:read", "metrics:read"},
+		Audience:     "https://api.example.com",
+	}
+	authenticator := NewAuthenticator()
+
+	apply := func() string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, "http://api.example.com", nil)
+		if err := authenticator.Apply(context.Background(), server.Client(), req, auth); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+		return req.Header.Get("Authorization")
+	}
+
+	if first, second := apply(), apply(); first != "Bearer token-1" || second != first {
+		t.Fatalf("headers = %q, %q", first, second)
+	}
+	if server.lastForm != "audience=https%3A%2F%2Fapi.example.com&grant_type=client_credentials&scope=health%3Aread+metrics%3Aread" {
+		t.Errorf("token request form = %q", server.lastForm)
+	}
+	if server.lastAuth != "Basic bW9uaXRvcjpzM2NyZXQ=" {
+		t.Errorf("token request auth = %q", server.lastAuth)
+	}
+
+	// Tokens are refreshed once they are within the margin of expiring
+	authenticator.tokens[tokenKey(*auth)].expires = time.Now().Add(tokenExpiryMargin - time.Second)
+	if header := apply(); header != "Bearer token-2" {
+		t.Errorf("after expiry = %q", header)
+	}
+
+	authenticator.Invalidate(auth)
+	if header := apply(); header != "Bearer token-3" {
+		t.Errorf("after invalidate = %q", header)
+	}
+
+	// A different secret is a different client
+	other := *auth
+	other.ClientSecret = "rotated"
+	req, _ := http.NewRequest(http.MethodGet, "http://api.example.com", nil)
+	authenticator.Apply(context.Background(), server.Client(), req, &other)
+	if header := req.Header.Get("Authorization"); header != "Bearer token-4" {
+		t.Errorf("other client = %q", header)
+	}
+}
+
+func TestOAuth2TokenDefaultLifetime(t *testing.T) {
+	server := newTokenServer(t, 0)
+	auth := AuthConfig{Type: AuthOAuth2, TokenURL: server.URL, ClientID: "monitor", ClientAuthStyle: "body"}
+	authenticator := NewAuthenticator()
+
+	if _, err := authenticator.token(context.Background(), server.Client(), auth); err != nil {
+		t.Fatalf("token: %v", err)
+	}
+
+	expires := authenticator.tokens[tokenKey(auth)].expires
+	if remaining := time.Until(expires); remaining < defaultTokenLifetime-time.Second || remaining > defaultTokenLifetime {
+		t.Errorf("token expires in %v", remaining)
+	}
+	if server.lastAuth != "" || !strings.Contains(server.lastForm, "client_id=monitor") {
+		t.Errorf("body auth sent form %q, header %q", server.lastForm, server.lastAuth)
+	}
+}
+
+func TestOAuth2TokenErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		err    string
+	}{
+		{http.StatusUnauthorized, `{"error":"invalid_client"}`, "error fetching oauth2 token"},
+		{http.StatusOK, `{"token_type":"Bearer"}`, "oauth2 token response has no access_token"},
+		{http.StatusOK, `<html>`, "error decoding oauth2 token response"},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			fmt.Fprint(w, test.body)
+		}))
+
+		auth := AuthConfig{Type: AuthOAuth2, TokenURL: server.URL, ClientID: "monitor"}
+		_, err := NewAuthenticator().token(context.Background(), server.Client(), auth)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("status %d: err = %v, want %q", test.status, err, test.err)
+		}
+		server.Close()
+	}
+}
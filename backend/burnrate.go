@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	AlertFiring   = "FIRING"
+	AlertResolved = "RESOLVED"
+
+	SeverityPage   = "page"
+	SeverityTicket = "ticket"
+
+	burnRateEvaluationInterval = time.Minute
+	burnRateAlertsCollection   = "burn_rate_alerts"
+)
+
+// BurnRateRule fires when the error budget burns at least threshold times
+// faster than sustainable over both windows. The long window makes the
+// alert significant, the short one makes it reset quickly after recovery.
+type BurnRateRule struct {
+	Name        string  `bson:"name,omitempty" json:"name,omitempty"`
+	LongWindow  string  `bson:"longWindow" json:"longWindow"`
+	ShortWindow string  `bson:"shortWindow" json:"shortWindow"`
+	Threshold   float64 `bson:"threshold" json:"threshold"`
+	Severity    string  `bson:"severity,omitempty" json:"severity,omitempty"` // page (default) or ticket
+}
+
+// Multiwindow, multi-burn-rate defaults from the SRE workbook for a 30 day budget
+var defaultBurnRateRules = []BurnRateRule{
+	{Name: "fast-burn", LongWindow: "1h", ShortWindow: "5m", Threshold: 14.4, Severity: SeverityPage},
+	{Name: "slow-burn", LongWindow: "6h", ShortWindow: "30m", Threshold: 6, Severity: SeverityPage},
+	{Name: "budget-leak", LongWindow: "3d", ShortWindow: "6h", Threshold: 1, Severity: SeverityTicket},
+}
+
+// BurnRateAlert is the stored state of one rule of one check, so alerts
+// that are firing survive a restart
+type BurnRateAlert struct {
+	CheckID   primitive.ObjectID `bson:"checkId"`
+	CheckName string             `bson:"checkName"`
+	Rule      string             `bson:"rule"`
+	Severity  string             `bson:"severity,omitempty"`
+	Firing    bool               `bson:"firing"`
+	Since     time.Time          `bson:"since"` // last transition
+}
+
+func (slo SLOConfig) burnRateRules() []BurnRateRule {
+	if len(slo.BurnRateAlerts) > 0 {
+		return slo.BurnRateAlerts
+	}
+	return defaultBurnRateRules
+}
+
+func (r BurnRateRule) key() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("%s-%s-%gx", r.LongWindow, r.ShortWindow, r.Threshold)
+}
+
+func (r BurnRateRule) severity() string {
+	if r.Severity == SeverityTicket {
+		return SeverityTicket
+	}
+	return SeverityPage
+}
+
+func (m *HealthCheckManager) evaluateBurnRatesPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(burnRateEvaluationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.evaluateBurnRates(ctx, time.Now())
+		}
+	}
+}
+
+// restoreBurnRateAlerts loads the rules that were firing when the process
+// stopped; alerts that cleared in the meantime resolve on the next evaluation
+func (m *HealthCheckManager) restoreBurnRateAlerts(ctx context.Context) {
+	cursor, err := m.mongoHelper.GetCollection(burnRateAlertsCollection).Find(ctx, bson.M{"firing": true})
+	if err != nil {
+		log.Printf("Failed to restore burn rate alerts: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var alerts []BurnRateAlert
+	if err := cursor.All(ctx, &alerts); err != nil {
+		log.Printf("Failed to restore burn rate alerts: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, alert := range alerts {
+		check, exists := m.checks[alert.CheckID.Hex()]
+		if !exists {
+			// The check was deleted or deactivated while the process was
+			// down; resolve the alert through its last known channels
+			check = m.findRetiredCheck(alert.CheckID)
+			if check == nil {
+				check = &ScheduledCheck{HealthCheck: m.findHealthCheckByID(ctx, alert.CheckID, alert.CheckName)}
+				m.retiredChecks = append(m.retiredChecks, check)
+			}
+		}
+		if check.firingBurnRules == nil {
+			check.firingBurnRules = make(map[string]bool)
+		}
+		check.firingBurnRules[alert.Rule] = true
+	}
+}
+
+// findHealthCheckByID loads a check that is not scheduled, falling back to
+// one without notification channels when it no longer exists
+func (m *HealthCheckManager) findHealthCheckByID(ctx context.Context, id primitive.ObjectID, name string) HealthCheck {
+	var hc HealthCheck
+	err := m.mongoHelper.GetCollection("healthchecks").FindOne(ctx, bson.M{"_id": id}).Decode(&hc)
+	if err != nil {
+		return HealthCheck{ID: id, Name: name}
+	}
+	return hc
+}
+
+// findRetiredCheck returns a removed check that still has alerts to
+// resolve; callers hold m.mu
+func (m *HealthCheckManager) findRetiredCheck(id primitive.ObjectID) *ScheduledCheck {
+	for _, check := range m.retiredChecks {
+		if check.HealthCheck.ID == id {
+			return check
+		}
+	}
+	return nil
+}
+
+// retireBurnRateAlerts keeps a removed check around until the next
+// evaluation resolves its firing alerts; callers hold m.mu
+func (m *HealthCheckManager) retireBurnRateAlerts(check *ScheduledCheck) {
+	for _, firing := range check.firingBurnRules {
+		if firing {
+			m.retiredChecks = append(m.retiredChecks, check)
+			return
+		}
+	}
+}
+
+func (m *HealthCheckManager) saveBurnRateAlert(ctx context.Context, alert BurnRateAlert) error {
+	_, err := m.mongoHelper.GetCollection(burnRateAlertsCollection).UpdateOne(ctx,
+		bson.M{"checkId": alert.CheckID, "rule": alert.Rule},
+		bson.M{"$set": alert},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error saving burn rate alert: %w", err)
+	}
+	return nil
+}
+
+func (m *HealthCheckManager) evaluateBurnRates(ctx context.Context, now time.Time) {
+	m.mu.Lock()
+	var checks []*ScheduledCheck
+	for _, check := range m.checks {
+		if check.HealthCheck.SLO != nil || len(check.firingBurnRules) > 0 {
+			checks = append(checks, check)
+		}
+	}
+	retired := m.retiredChecks
+	m.retiredChecks = nil
+	m.mu.Unlock()
+
+	for _, check := range retired {
+		m.resolveRemovedBurnRules(ctx, check, check.HealthCheck, nil, "check deleted or deactivated", now)
+	}
+
+	for _, check := range checks {
+		m.mu.RLock()
+		hc := check.HealthCheck
+		m.mu.RUnlock()
+
+		current := make(map[string]bool)
+		reason := "burn rate rule removed from the check"
+		if hc.SLO != nil {
+			if err := hc.SLO.validate(); err != nil {
+				log.Printf("[%s] Skipping burn rate alerts: %v", hc.Name, err)
+				reason = err.Error()
+			} else {
+				for _, rule := range hc.SLO.burnRateRules() {
+					current[rule.key()] = true
+					if err := m.evaluateBurnRateRule(ctx, check, hc, rule, now); err != nil {
+						log.Printf("[%s] Failed to evaluate burn rate rule %s: %v", hc.Name, rule.key(), err)
+					}
+				}
+			}
+		}
+
+		m.resolveRemovedBurnRules(ctx, check, hc, current, reason, now)
+	}
+}
+
+// resolveRemovedBurnRules resolves alerts whose rule (or the whole slo or
+// check) was removed while firing
+func (m *HealthCheckManager) resolveRemovedBurnRules(ctx context.Context, check *ScheduledCheck, hc HealthCheck, current map[string]bool, reason string, now time.Time) {
+	var removed []string
+	m.mu.Lock()
+	for key, firing := range check.firingBurnRules {
+		if !current[key] {
+			delete(check.firingBurnRules, key)
+			if firing {
+				removed = append(removed, key)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	for _, key := range removed {
+		notification := Notification{
+			Event:     EventBurnRate,
+			CheckID:   hc.ID.Hex(),
+			CheckName: hc.Name,
+			URL:       hc.URL,
+			From:      AlertFiring,
+			To:        AlertResolved,
+			Rule:      key,
+			Reason:    reason,
+			Timestamp: now,
+		}
+		m.notifyBurnRate(ctx, hc, BurnRateAlert{CheckID: hc.ID, CheckName: hc.Name, Rule: key, Since: now}, notification)
+	}
+}
+
+func (m *HealthCheckManager) evaluateBurnRateRule(ctx context.Context, check *ScheduledCheck, hc HealthCheck, rule BurnRateRule, now time.Time) error {
+	longRate, err := m.burnRate(ctx, hc, rule.LongWindow, now)
+	if err != nil {
+		return err
+	}
+	shortRate, err := m.burnRate(ctx, hc, rule.ShortWindow, now)
+	if err != nil {
+		return err
+	}
+
+	firing := longRate >= rule.Threshold && shortRate >= rule.Threshold
+
+	m.mu.Lock()
+	if check.firingBurnRules == nil {
+		check.firingBurnRules = make(map[string]bool)
+	}
+	wasFiring := check.firingBurnRules[rule.key()]
+	check.firingBurnRules[rule.key()] = firing
+	m.mu.Unlock()
+
+	if firing == wasFiring {
+		return nil
+	}
+
+	notification := Notification{
+		Event:     EventBurnRate,
+		CheckID:   hc.ID.Hex(),
+		CheckName: hc.Name,
+		URL:       hc.URL,
+		From:      AlertResolved,
+		To:        AlertFiring,
+		Rule:      rule.key(),
+		Severity:  rule.severity(),
+		Reason: fmt.Sprintf("error budget burning at %.1fx over %s and %.1fx over %s (threshold %gx)",
+			longRate, rule.LongWindow, shortRate, rule.ShortWindow, rule.Threshold),
+		Detail:    fmt.Sprintf("SLO target %g%%, rule %s", hc.SLO.Target, rule.key()),
+		Timestamp: now,
+	}
+	if !firing {
+		notification.From, notification.To = AlertFiring, AlertResolved
+	}
+
+	m.notifyBurnRate(ctx, hc, BurnRateAlert{
+		CheckID:   hc.ID,
+		CheckName: hc.Name,
+		Rule:      rule.key(),
+		Severity:  rule.severity(),
+		Firing:    firing,
+		Since:     now,
+	}, notification)
+
+	return nil
+}
+
+func (m *HealthCheckManager) notifyBurnRate(ctx context.Context, hc HealthCheck, alert BurnRateAlert, notification Notification) {
+	if err := m.saveBurnRateAlert(ctx, alert); err != nil {
+		log.Printf("[%s] %v", hc.Name, err)
+	}
+
+	log.Printf("[%s] Burn rate alert %s %s: %s", hc.Name, alert.Rule, notification.To, notification.Reason)
+	m.notifier.Notify(ctx, hc.Notifications, notification)
+}
+
+func (m *HealthCheckManager) burnRate(ctx context.Context, hc HealthCheck, window string, now time.Time) (float64, error) {
+	duration, err := parseWindow(window)
+	if err != nil {
+		return 0, err
+	}
+
+	good, total, err := m.countGoodProbes(ctx, hc.Name, *hc.SLO, now.Add(-duration), now)
+	if err != nil {
+		return 0, err
+	}
+
+	return NewSLOReport(*hc.SLO, good, total).BurnRate, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recordingNotifier stands in for a channel type and keeps what was sent
+type recordingNotifier struct {
+	channelType string
+
+	mu   sync.Mutex
+	sent []Notification
+	done chan struct{}
+}
+
+func (n *recordingNotifier) Type() string {
+	return n.channelType
+}
+
+func (n *recordingNotifier) Send(ctx context.Context, channel NotificationChannel, notification Notification) error {
+	n.mu.Lock()
+	n.sent = append(n.sent, notification)
+	n.mu.Unlock()
+	n.done <- struct{}{}
+	return nil
+}
+
+func TestRemovedBurnRuleResolves(t *testing.T) {
+	dispatcher := newTestDispatcher(t)
+	notifier := &recordingNotifier{channelType: ChannelWebhook, done: make(chan struct{}, 10)}
+	dispatcher.Register(notifier)
+
+	m := NewHealthCheckManager(dispatcher.mongoHelper.db, dispatcher, nil, 1)
+
+	hc := HealthCheck{
+		ID:            primitive.NewObjectID(),
+		Name:          "api",
+		Notifications: []NotificationChannel{{Type: ChannelWebhook, URL: "http://127.0.0.1:1"}},
+	}
+	check := newScheduledCheck(hc, time.Now())
+	check.firingBurnRules = map[string]bool{"fast-burn": true, "slow-burn": false, "budget-leak": true}
+	m.checks[hc.ID.Hex()] = check
+
+	// The check no longer has an slo, so every rule is gone
+	m.evaluateBurnRates(context.Background(), time.Now())
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-notifier.done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d notifications, want 2", i)
+		}
+	}
+
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	rules := make(map[string]bool)
+	for _, notification := range notifier.sent {
+		if notification.Event != EventBurnRate || notification.From != AlertFiring || notification.To != AlertResolved {
+			t.Errorf("notification = %+v", notification)
+		}
+		rules[notification.Rule] = true
+	}
+	if !rules["fast-burn"] || !rules["budget-leak"] {
+		t.Errorf("resolved rules = %v", rules)
+	}
+	if len(check.firingBurnRules) != 0 {
+		t.Errorf("firing rules left: %v", check.firingBurnRules)
+	}
+
+	// Nothing is left to resolve on the next evaluation
+	m.evaluateBurnRates(context.Background(), time.Now())
+	select {
+	case <-notifier.done:
+		t.Error("resolved a rule twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRemovedCheckResolvesBurnRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		slo    *SLOConfig
+		remove bool
+		reason string
+	}{
+		{"deleted check", &SLOConfig{Target: 99.9}, true, "check deleted or deactivated"},
+		{"target of 100", &SLOConfig{Target: 100}, false, "slo target must be above 0 and below 100, got 100"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dispatcher := newTestDispatcher(t)
+			notifier := &recordingNotifier{channelType: ChannelWebhook, done: make(chan struct{}, 10)}
+			dispatcher.Register(notifier)
+
+			m := NewHealthCheckManager(dispatcher.mongoHelper.db, dispatcher, nil, 1)
+
+			hc := HealthCheck{
+				ID:            primitive.NewObjectID(),
+				Name:          "api",
+				SLO:           test.slo,
+				Notifications: []NotificationChannel{{Type: ChannelWebhook, URL: "http://127.0.0.1:1"}},
+			}
+			check := newScheduledCheck(hc, time.Now())
+			check.firingBurnRules = map[string]bool{"fast-burn": true}
+			m.checks[hc.ID.Hex()] = check
+			if test.remove {
+				m.mu.Lock()
+				m.removeHealthCheck(hc.ID.Hex(), "deleted")
+				m.mu.Unlock()
+			}
+
+			m.evaluateBurnRates(context.Background(), time.Now())
+
+			select {
+			case <-notifier.done:
+			case <-time.After(2 * time.Second):
+				t.Fatal("burn rate alert was not resolved")
+			}
+			notifier.mu.Lock()
+			notification := notifier.sent[0]
+			notifier.mu.Unlock()
+			if notification.Rule != "fast-burn" || notification.To != AlertResolved || notification.Reason != test.reason {
+				t.Errorf("notification = %+v", notification)
+			}
+			if len(m.retiredChecks) != 0 || len(check.firingBurnRules) != 0 {
+				t.Errorf("retired = %d, firing = %v", len(m.retiredChecks), check.firingBurnRules)
+			}
+		})
+	}
+}
+
+func TestBurnRateRules(t *testing.T) {
+	custom := []BurnRateRule{
+		{LongWindow: "2h", ShortWindow: "10m", Threshold: 8},
+		{Name: "weekly", LongWindow: "7d", ShortWindow: "1d", Threshold: 1.5, Severity: SeverityTicket},
+	}
+
+	tests := []struct {
+		slo        SLOConfig
+		keys       []string
+		severities []string
+	}{
+		{SLOConfig{Target: 99.9}, []string{"fast-burn", "slow-burn", "budget-leak"}, []string{SeverityPage, SeverityPage, SeverityTicket}},
+		{SLOConfig{Target: 99.9, BurnRateAlerts: custom}, []string{"2h-10m-8x", "weekly"}, []string{SeverityPage, SeverityTicket}},
+	}
+
+	for _, test := range tests {
+		rules := test.slo.burnRateRules()
+		if len(rules) != len(test.keys) {
+			t.Fatalf("rules = %+v", rules)
+		}
+		for i, rule := range rules {
+			if rule.key() != test.keys[i] || rule.severity() != test.severities[i] {
+				t.Errorf("rule %d: key = %q, severity = %q", i, rule.key(), rule.severity())
+			}
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	AssertionDNSAnswer = "dnsAnswer"
+	AssertionDNSTTL    = "ttl"
+)
+
+var dnsRecordTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"SRV":   dnsmessage.TypeSRV,
+}
+
+type DNSCheckConfig struct {
+	Query      string   `bson:"query,omitempty"`      // defaults to the host in url
+	RecordType string   `bson:"recordType,omitempty"` // A (default), AAAA, CNAME, MX, TXT or SRV
+	Nameserver string   `bson:"nameserver,omitempty"` // host[:port], defaults to the system resolver
+	Protocol   string   `bson:"protocol,omitempty"`   // udp (default) or tcp
+	Expected   []string `bson:"expected,omitempty"`   // answers that must be present
+	ExactMatch bool     `bson:"exactMatch,omitempty"` // fail on answers that are not expected
+	MinTTL     int      `bson:"minTtl,omitempty"`
+	MaxTTL     int      `bson:"maxTtl,omitempty"`
+}
+
+type DNSAnswer struct {
+	Type  string `bson:"type"`
+	Value string `bson:"value"`
+	TTL   uint32 `bson:"ttl"`
+}
+
+type DNSResult struct {
+	Query      string      `bson:"query"`
+	RecordType string      `bson:"recordType"`
+	Nameserver string      `bson:"nameserver"`
+	Rcode      string      `bson:"rcode"`
+	Answers    []DNSAnswer `bson:"answers,omitempty"`
+}
+
+func (m *HealthCheckManager) probeDNS(ctx context.Context, hc HealthCheck) HealthCheckLog {
+	start := time.Now()
+
+	config := DNSCheckConfig{}
+	if hc.DNS != nil {
+		config = *hc.DNS
+	}
+
+	query := config.Query
+	if query == "" {
+		query = hostFromTarget(hc.URL)
+	}
+	if query == "" {
+		return newFailedLog(start, 0, fmt.Errorf("dns query name is required"))
+	}
+
+	recordType := strings.ToUpper(config.RecordType)
+	if recordType == "" {
+		recordType = "A"
+	}
+	qtype, ok := dnsRecordTypes[recordType]
+	if !ok {
+		return newFailedLog(start, 0, fmt.Errorf("unsupported record type '%s'", config.RecordType))
+	}
+
+	nameserver := config.Nameserver
+	if nameserver == "" {
+		nameserver = systemNameserver()
+	}
+	if _, _, err := net.SplitHostPort(nameserver); err != nil {
+		nameserver = net.JoinHostPort(nameserver, "53")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout())
+	defer cancel()
+
+	result, err := resolveDNS(ctx, nameserver, config.Protocol, query, qtype)
+	if err != nil {
+		return newFailedLog(start, 0, err)
+	}
+	result.RecordType = recordType
+
+	logEntry := HealthCheckLog{
+		Timestamp:    time.Now(),
+		ResponseTime: time.Since(start).Milliseconds(),
+		DNS:          result,
+	}
+
+	if result.Rcode != rcodeName(dnsmessage.RCodeSuccess) {
+		errMsg := fmt.Sprintf("query for %s %s returned %s", recordType, query, result.Rcode)
+		logEntry.Error = &errMsg
+		logEntry.ErrorClass = ErrorClassDNS
+		return logEntry
+	}
+	if len(result.Answers) == 0 {
+		errMsg := fmt.Sprintf("no %s records for %s", recordType, query)
+		logEntry.Error = &errMsg
+		logEntry.ErrorClass = ErrorClassDNS
+		return logEntry
+	}
+
+	logEntry.Assertions = evaluateDNSAnswers(config, recordType, result.Answers)
+	logEntry.Success = AssertionsPassed(logEntry.Assertions)
+
+	return logEntry
+}
+
+func evaluateDNSAnswers(config DNSCheckConfig, recordType string, answers []DNSAnswer) []AssertionResult {
+	var results []AssertionResult
+
+	actual := make(map[string]bool, len(answers))
+	values := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		value := normalizeDNSValue(recordType, answer.Value)
+		actual[value] = true
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	joined := strings.Join(values, ", ")
+
+	expected := make(map[string]bool, len(config.Expected))
+	for _, value := range config.Expected {
+		value = normalizeDNSValue(recordType, value)
+		expected[value] = true
+
+		result := AssertionResult{
+			Type:     AssertionDNSAnswer,
+			Operator: OperatorContains,
+			Expected: value,
+			Actual:   joined,
+			Passed:   actual[value],
+		}
+		if !result.Passed {
+			result.Message = fmt.Sprintf("answer '%s' not returned", value)
+		}
+		results = append(results, result)
+	}
+
+	if config.ExactMatch {
+		var unexpected []string
+		for _, value := range values {
+			if !expected[value] {
+				unexpected = append(unexpected, value)
+			}
+		}
+		result := AssertionResult{
+			Type:     AssertionDNSAnswer,
+			Operator: OperatorEquals,
+			Expected: strings.Join(config.Expected, ", "),
+			Actual:   joined,
+			Passed:   len(unexpected) == 0,
+		}
+		if !result.Passed {
+			result.Message = fmt.Sprintf("unexpected answers: %s", strings.Join(unexpected, ", "))
+		}
+		results = append(results, result)
+	}
+
+	for _, answer := range answers {
+		ttl := int(answer.TTL)
+		if config.MinTTL > 0 {
+			result := AssertionResult{
+				Type:     AssertionDNSTTL,
+				Target:   answer.Value,
+				Operator: OperatorGreaterThanOrEqual,
+				Expected: strconv.Itoa(config.MinTTL),
+				Actual:   strconv.Itoa(ttl),
+				Passed:   ttl >= config.MinTTL,
+			}
+			if !result.Passed {
+				result.Message = fmt.Sprintf("ttl %d below minimum %d", ttl, config.MinTTL)
+			}
+			results = append(results, result)
+		}
+		if config.MaxTTL > 0 {
+			result := AssertionResult{
+				Type:     AssertionDNSTTL,
+				Target:   answer.Value,
+				Operator: OperatorLessThanOrEqual,
+				Expected: strconv.Itoa(config.MaxTTL),
+				Actual:   strconv.Itoa(ttl),
+				Passed:   ttl <= config.MaxTTL,
+			}
+			if !result.Passed {
+				result.Message = fmt.Sprintf("ttl %d above maximum %d", ttl, config.MaxTTL)
+			}
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+func resolveDNS(ctx context.Context, nameserver, protocol, query string, qtype dnsmessage.Type) (*DNSResult, error) {
+	fqdn := query
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dns name '%s': %w", query, err)
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := builder.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	request, err := builder.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("error building dns query: %w", err)
+	}
+
+	if protocol != "tcp" {
+		protocol = "udp"
+	}
+
+	response, err := exchangeDNS(ctx, protocol, nameserver, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing dns response: %w", err)
+	}
+
+	// Answers that do not fit a UDP datagram are retried over TCP
+	if header.Truncated && protocol == "udp" {
+		if response, err = exchangeDNS(ctx, "tcp", nameserver, request); err != nil {
+			return nil, err
+		}
+		if header, err = parser.Start(response); err != nil {
+			return nil, fmt.Errorf("error parsing dns response: %w", err)
+		}
+	}
+
+	if header.ID != id {
+		return nil, fmt.Errorf("dns response id mismatch")
+	}
+
+	result := &DNSResult{
+		Query:      query,
+		Nameserver: nameserver,
+		Rcode:      rcodeName(header.RCode),
+	}
+
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, fmt.Errorf("error parsing dns response: %w", err)
+	}
+
+	for {
+		answerHeader, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing dns answer: %w", err)
+		}
+
+		// CNAME chains are returned alongside A/AAAA answers; keep only the requested type
+		if answerHeader.Type != qtype {
+			if err := parser.SkipAnswer(); err != nil {
+				return nil, fmt.Errorf("error parsing dns answer: %w", err)
+			}
+			continue
+		}
+
+		value, err := parseDNSAnswer(&parser, qtype)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing dns answer: %w", err)
+		}
+
+		result.Answers = append(result.Answers, DNSAnswer{
+			Type:  strings.TrimPrefix(qtype.String(), "Type"),
+			Value: value,
+			TTL:   answerHeader.TTL,
+		})
+	}
+
+	return result, nil
+}
+
+func parseDNSAnswer(parser *dnsmessage.Parser, qtype dnsmessage.Type) (string, error) {
+	switch qtype {
+	case dnsmessage.TypeA:
+		resource, err := parser.AResource()
+		if err != nil {
+			return "", err
+		}
+		return net.IP(resource.A[:]).String(), nil
+	case dnsmessage.TypeAAAA:
+		resource, err := parser.AAAAResource()
+		if err != nil {
+			return "", err
+		}
+		return net.IP(resource.AAAA[:]).String(), nil
+	case dnsmessage.TypeCNAME:
+		resource, err := parser.CNAMEResource()
+		if err != nil {
+			return "", err
+		}
+		return resource.CNAME.String(), nil
+	case dnsmessage.TypeMX:
+		resource, err := parser.MXResource()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d %s", resource.Pref, resource.MX.String()), nil
+	case dnsmessage.TypeTXT:
+		resource, err := parser.TXTResource()
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(resource.TXT, ""), nil
+	case dnsmessage.TypeSRV:
+		resource, err := parser.SRVResource()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d %d %d %s", resource.Priority, resource.Weight, resource.Port, resource.Target.String()), nil
+	default:
+		return "", fmt.Errorf("unsupported record type %s", qtype)
+	}
+}
+
+func exchangeDNS(ctx context.Context, protocol, nameserver string, request []byte) ([]byte, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, protocol, nameserver)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if protocol == "udp" {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+		buffer := make([]byte, 4096)
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+		return buffer[:n], nil
+	}
+
+	// DNS over TCP prefixes every message with its length
+	framed := make([]byte, 2+len(request))
+	binary.BigEndian.PutUint16(framed, uint16(len(request)))
+	copy(framed[2:], request)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func rcodeName(rcode dnsmessage.RCode) string {
+	switch rcode {
+	case dnsmessage.RCodeSuccess:
+		return "NOERROR"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	default:
+		return rcode.String()
+	}
+}
+
+// normalizeDNSValue makes names comparable; TXT data is case sensitive
+func normalizeDNSValue(recordType, value string) string {
+	if recordType == "TXT" {
+		return value
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), ".")
+}
+
+// hostFromTarget accepts "dns://example.com" as well as a bare name
+func hostFromTarget(target string) string {
+	if !strings.Contains(target, "://") {
+		return strings.TrimSpace(target)
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+func systemNameserver() string {
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1]
+		}
+	}
+	return "127.0.0.1"
+}
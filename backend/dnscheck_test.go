@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func TestEvaluateDNSAnswersTTL(t *testing.T) {
+	config := DNSCheckConfig{MinTTL: 60, MaxTTL: 3600}
+	answers := []DNSAnswer{
+		{Type: "A", Value: "192.0.2.1", TTL: 300},
+		{Type: "A", Value: "192.0.2.2", TTL: 30},
+		{Type: "A", Value: "192.0.2.3", TTL: 3600}, // the bounds are inclusive
+	}
+
+	results := evaluateDNSAnswers(config, "A", answers)
+
+	if len(results) != 6 {
+		t.Fatalf("got %d results, want one per bound and answer: %+v", len(results), results)
+	}
+	for _, result := range results {
+		if result.Type != AssertionDNSTTL {
+			t.Errorf("unexpected result %+v", result)
+		}
+		failing := result.Target == "192.0.2.2" && result.Operator == OperatorGreaterThanOrEqual
+		if result.Passed == failing {
+			t.Errorf("result %+v: passed = %v", result, result.Passed)
+		}
+		if failing && result.Message != "ttl 30 below minimum 60" {
+			t.Errorf("message = %q", result.Message)
+		}
+		if !failing && result.Message != "" {
+			t.Errorf("passing result has message %q", result.Message)
+		}
+	}
+	if AssertionsPassed(results) {
+		t.Error("assertions passed with a ttl below the minimum")
+	}
+}
+
+func TestEvaluateDNSAnswersExpected(t *testing.T) {
+	config := DNSCheckConfig{Expected: []string{"192.0.2.1"}, ExactMatch: true}
+	answers := []DNSAnswer{{Type: "A", Value: "192.0.2.1", TTL: 300}}
+
+	results := evaluateDNSAnswers(config, "A", answers)
+
+	if len(results) != 2 || !AssertionsPassed(results) {
+		t.Fatalf("results = %+v", results)
+	}
+}
+
+func TestEvaluateDNSAnswersMatching(t *testing.T) {
+	answers := []DNSAnswer{
+		{Type: "CNAME", Value: "Edge.Example.NET.", TTL: 300},
+		{Type: "CNAME", Value: "backup.example.net.", TTL: 300},
+	}
+
+	tests := []struct {
+		name   string
+		config DNSCheckConfig
+		passed []bool
+	}{
+		{"case and trailing dot ignored", DNSCheckConfig{Expected: []string{"edge.example.net"}}, []bool{true}},
+		{"missing answer", DNSCheckConfig{Expected: []string{"edge.example.net", "other.example.net."}}, []bool{true, false}},
+		{"exact match", DNSCheckConfig{Expected: []string{"edge.example.net", "backup.example.net"}, ExactMatch: true}, []bool{true, true, true}},
+		{"unexpected answer", DNSCheckConfig{Expected: []string{"edge.example.net"}, ExactMatch: true}, []bool{true, false}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results := evaluateDNSAnswers(test.config, "CNAME", answers)
+			if len(results) != len(test.passed) {
+				t.Fatalf("results = %+v", results)
+			}
+			for i, result := range results {
+				if result.Passed != test.passed[i] || result.Actual != "backup.example.net, edge.example.net" {
+					t.Errorf("result %d = %+v", i, result)
+				}
+			}
+		})
+	}
+
+	results := evaluateDNSAnswers(DNSCheckConfig{Expected: []string{"edge.example.net"}, ExactMatch: true}, "CNAME", answers)
+	if results[1].Message != "unexpected answers: backup.example.net" {
+		t.Errorf("message = %q", results[1].Message)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ChannelEmail = "email"
+
+type EmailNotifier struct {
+	timeout time.Duration
+}
+
+func NewEmailNotifier() *EmailNotifier {
+	return &EmailNotifier{timeout: 10 * time.Second}
+}
+
+func (n *EmailNotifier) Type() string {
+	return ChannelEmail
+}
+
+func (n *EmailNotifier) Send(ctx context.Context, channel NotificationChannel, notification Notification) error {
+	host := firstNonEmpty(channel.SMTPHost, os.Getenv("SMTP_HOST"))
+	if host == "" {
+		return fmt.Errorf("smtp host is required")
+	}
+	if len(channel.To) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+
+	port := channel.SMTPPort
+	if port == 0 {
+		port, _ = strconv.Atoi(os.Getenv("SMTP_PORT"))
+	}
+	if port == 0 {
+		port = 587
+	}
+
+	username := firstNonEmpty(channel.Username, os.Getenv("SMTP_USERNAME"))
+	password := firstNonEmpty(channel.Password, os.Getenv("SMTP_PASSWORD"))
+	from := firstNonEmpty(channel.From, os.Getenv("SMTP_FROM"), "hst@localhost")
+
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("error connecting to smtp server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error starting smtp session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("error starting tls: %w", err)
+		}
+	}
+
+	if username != "" {
+		if err := client.Auth(smtp.PlainAuth("", username, password, host)); err != nil {
+			return fmt.Errorf("smtp authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	for _, recipient := range channel.To {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s failed: %w", recipient, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := writer.Write(buildEmailMessage(from, channel.To, notification)); err != nil {
+		writer.Close()
+		return fmt.Errorf("error writing email: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	return client.Quit()
+}
+
+func buildEmailMessage(from string, to []string, notification Notification) []byte {
+	var message strings.Builder
+
+	message.WriteString("From: " + from + "\r\n")
+	message.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	message.WriteString("Subject: " + notification.Summary() + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.ReplaceAll(notification.Description(), "\n", "\r\n"))
+	message.WriteString("\r\n")
+
+	return []byte(message.String())
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is a minimal SMTP server that accepts one message per session
+// and records the envelope, the AUTH PLAIN credentials and the data.
+type fakeSMTP struct {
+	listener net.Listener
+
+	mu   sync.Mutex
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTP{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH PLAIN"):
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(line[len("AUTH PLAIN"):]))
+			s.mu.Lock()
+			s.auth = string(decoded)
+			s.mu.Unlock()
+			reply("235 ok")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			s.mu.Unlock()
+			reply("250 ok")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.mu.Lock()
+			s.to = append(s.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			s.mu.Unlock()
+			reply("250 ok")
+		case command == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestEmailDelivery(t *testing.T) {
+	server := newFakeSMTP(t)
+
+	channel := NotificationChannel{
+		Type:     ChannelEmail,
+		SMTPHost: "127.0.0.1",
+		SMTPPort: server.port(),
+		Username: "alerts",
+		Password: "hunter2",
+		From:     "hst@example.com",
+		To:       []string{"oncall@example.com", "team@example.com"},
+	}
+	notification := testNotification(StateDown)
+
+	if err := NewEmailNotifier().Send(context.Background(), channel, notification); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.auth != "\x00alerts\x00hunter2" {
+		t.Errorf("auth = %q", server.auth)
+	}
+	if server.from != "hst@example.com" {
+		t.Errorf("from = %q", server.from)
+	}
+	if strings.Join(server.to, ",") != "oncall@example.com,team@example.com" {
+		t.Errorf("recipients = %v", server.to)
+	}
+
+	for _, want := range []string{
+		"From: hst@example.com\r\n",
+		"To: oncall@example.com, team@example.com\r\n",
+		"Subject: " + notification.Summary() + "\r\n",
+		"Content-Type: text/plain; charset=UTF-8\r\n",
+		"Check: api\r\n",
+		"State: UP -> DOWN\r\n",
+		"Reason: 3 consecutive failures\r\n",
+	} {
+		if !strings.Contains(server.data, want) {
+			t.Errorf("message is missing %q:\n%s", want, server.data)
+		}
+	}
+}
+
+func TestEmailUsesEnvironmentDefaults(t *testing.T) {
+	server := newFakeSMTP(t)
+
+	t.Setenv("SMTP_HOST", "127.0.0.1")
+	t.Setenv("SMTP_PORT", strconv.Itoa(server.port()))
+	t.Setenv("SMTP_USERNAME", "")
+	t.Setenv("SMTP_FROM", "")
+
+	channel := NotificationChannel{Type: ChannelEmail, To: []string{"oncall@example.com"}}
+	if err := NewEmailNotifier().Send(context.Background(), channel, testNotification(StateUp)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.auth != "" {
+		t.Errorf("authenticated without a username: %q", server.auth)
+	}
+	if server.from != "hst@localhost" {
+		t.Errorf("from = %q", server.from)
+	}
+	if !strings.Contains(server.data, "Subject: [UP] api") {
+		t.Errorf("message = %s", server.data)
+	}
+}
+
+func TestEmailRequiresHostAndRecipients(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+
+	notifier := NewEmailNotifier()
+	if err := notifier.Send(context.Background(), NotificationChannel{Type: ChannelEmail, To: []string{"a@example.com"}}, testNotification(StateDown)); err == nil {
+		t.Error("expected an error without smtp host")
+	}
+	if err := notifier.Send(context.Background(), NotificationChannel{Type: ChannelEmail, SMTPHost: "127.0.0.1"}, testNotification(StateDown)); err == nil {
+		t.Error("expected an error without recipients")
+	}
+}
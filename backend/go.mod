@@ -2,7 +2,11 @@ module hst/backend
 
 go 1.25.3
 
-require go.mongodb.org/mongo-driver v1.17.4
+require (
+	go.mongodb.org/mongo-driver v1.17.4
+	golang.org/x/net v0.28.0
+	google.golang.org/grpc v1.66.0
+)
 
 require (
 	github.com/golang/snappy v0.0.4 // indirect
@@ -14,5 +18,8 @@ require (
 	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78 // indirect
 	golang.org/x/crypto v0.26.0 // indirect
 	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/sys v0.23.0 // indirect
 	golang.org/x/text v0.17.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240604185151-ef581f913117 // indirect
+	google.golang.org/protobuf v1.34.1 // indirect
 )
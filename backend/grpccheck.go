@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const AssertionGRPCStatus = "grpcStatus"
+
+type GRPCCheckConfig struct {
+	Service            string `bson:"service,omitempty"` // empty checks the server as a whole
+	TLS                bool   `bson:"tls,omitempty"`     // implied by a grpcs:// url
+	InsecureSkipVerify bool   `bson:"insecureSkipVerify,omitempty"`
+}
+
+func (m *HealthCheckManager) probeGRPC(ctx context.Context, hc HealthCheck) HealthCheckLog {
+	start := time.Now()
+
+	address, err := targetAddress(hc.URL)
+	if err != nil {
+		return newFailedLog(start, 0, err)
+	}
+
+	config := GRPCCheckConfig{}
+	if hc.GRPC != nil {
+		config = *hc.GRPC
+	}
+
+	host, _, _ := net.SplitHostPort(address)
+
+	// The transport options apply as for http checks; grpc has no redirects
+	transport := hc.httpClientConfig()
+	tlsConfig, err := transport.TLSConfig()
+	if err != nil {
+		return newFailedLog(start, 0, fmt.Errorf("invalid transport options: %w", err))
+	}
+
+	creds := insecure.NewCredentials()
+	if config.TLS || strings.HasPrefix(hc.URL, "grpcs://") || tlsConfig != nil {
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		tlsConfig.ServerName = host
+		tlsConfig.InsecureSkipVerify = tlsConfig.InsecureSkipVerify || config.InsecureSkipVerify
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	options := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if transport.ProxyURL != "" {
+		dialer, err := proxyDialer(transport.ProxyURL)
+		if err != nil {
+			return newFailedLog(start, 0, fmt.Errorf("invalid transport options: %w", err))
+		}
+		options = append(options, grpc.WithContextDialer(dialer))
+		// The proxy resolves the host, as it does for http checks
+		address = "passthrough:///" + address
+	}
+
+	conn, err := grpc.NewClient(address, options...)
+	if err != nil {
+		return newFailedLog(start, 0, fmt.Errorf("error creating grpc client: %w", err))
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout())
+	defer cancel()
+
+	if hc.Auth != nil {
+		client, _, err := m.httpClient(hc)
+		if err != nil {
+			return newFailedLog(start, 0, err)
+		}
+		header, err := m.auth.Header(ctx, client, hc.Auth)
+		if err != nil {
+			return newFailedLog(start, 0, err)
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", header)
+	}
+
+	var remote peer.Peer
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: config.Service,
+	}, grpc.Peer(&remote))
+	if err != nil {
+		// A revoked token is fetched again on the next run instead of at expiry
+		if status.Code(err) == codes.Unauthenticated {
+			m.auth.Invalidate(hc.Auth)
+		}
+		logEntry := newFailedLog(start, 0, err)
+		logEntry.ErrorClass = grpcErrorClass(err)
+		// The health service answers NotFound for services it does not know
+		if status.Code(err) == codes.NotFound {
+			logEntry.GRPCStatus = healthpb.HealthCheckResponse_SERVICE_UNKNOWN.String()
+		}
+		return logEntry
+	}
+
+	servingStatus := resp.GetStatus().String()
+
+	result := AssertionResult{
+		Type:     AssertionGRPCStatus,
+		Target:   config.Service,
+		Operator: OperatorEquals,
+		Expected: healthpb.HealthCheckResponse_SERVING.String(),
+		Actual:   servingStatus,
+		Passed:   resp.GetStatus() == healthpb.HealthCheckResponse_SERVING,
+	}
+	if !result.Passed {
+		result.Message = fmt.Sprintf("service reported %s", servingStatus)
+	}
+
+	logEntry := HealthCheckLog{
+		Timestamp:    time.Now(),
+		ResponseTime: time.Since(start).Milliseconds(),
+		Success:      result.Passed,
+		Assertions:   []AssertionResult{result},
+		GRPCStatus:   servingStatus,
+	}
+
+	if tlsInfo, ok := remote.AuthInfo.(credentials.TLSInfo); ok {
+		logEntry.TLS = InspectTLS(&tlsInfo.State, host, tlsConfig.RootCAs, start)
+		if logEntry.Success {
+			applyTLSRules(hc, &logEntry, start)
+		}
+	}
+
+	return logEntry
+}
+
+func grpcErrorClass(err error) string {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded:
+		return ErrorClassTimeout
+	case codes.Unavailable:
+		return ErrorClassConnection
+	default:
+		return ErrorClassOther
+	}
+}
+
+// proxyDialer connects through an HTTP CONNECT or SOCKS5 proxy, the proxy
+// kinds the http transport supports
+func proxyDialer(rawURL string) (func(context.Context, string) (net.Conn, error), error) {
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		return func(ctx context.Context, address string) (net.Conn, error) {
+			return dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", address)
+		}, nil
+	case "http", "https":
+		return func(ctx context.Context, address string) (net.Conn, error) {
+			return dialConnect(ctx, proxyURL, address)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme '%s'", proxyURL.Scheme)
+	}
+}
+
+// dialConnect opens a tunnel to address with an HTTP CONNECT request
+func dialConnect(ctx context.Context, proxyURL *url.URL, address string) (net.Conn, error) {
+	proxyAddress := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddress = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddress)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to proxy: %w", err)
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error connecting to proxy: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error sending CONNECT to proxy: %w", err)
+	}
+
+	// The server speaks only after the client preface, so nothing beyond the
+	// response is buffered
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error reading CONNECT response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT: %s", resp.Status)
+	}
+
+	return conn, nil
+}
@@ -1,226 +1,440 @@
-package main
-
-import (
-	"context"
-	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"strings"
-	"sync"
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-type HealthCheck struct {
-	ID           primitive.ObjectID `bson:"_id"`
-	Name         string             `bson:"name"`
-	URL          string             `bson:"url"`
-	Method       string             `bson:"method"`
-	Interval     int                `bson:"interval"`
-	StatusCode   int                `bson:"statusCode"`
-	Headers      map[string]string  `bson:"headers"`
-	ExpectedBody *string            `bson:"expectedBody"`
-	Status       string             `bson:"status"`
-	CreatedAt    time.Time          `bson:"createdAt"`
-}
-
-type HealthCheckLog struct {
-	Timestamp    time.Time `bson:"timestamp"`
-	StatusCode   int       `bson:"statusCode"`
-	ResponseTime int64     `bson:"responseTime"`
-	Success      bool      `bson:"success"`
-	Error        *string   `bson:"error,omitempty"`
-}
-
-type HealthCheckCounter struct {
-	HealthCheck HealthCheck
-	Counter     int
-}
-
-type HealthCheckManager struct {
-	db          *mongo.Database
-	mongoHelper *MongoHelper
-	clock       *Clock
-	counters    map[string]*HealthCheckCounter
-	mu          sync.RWMutex
-	client      *http.Client
-}
-
-func NewHealthCheckManager(db *mongo.Database, clock *Clock) *HealthCheckManager {
-	return &HealthCheckManager{
-		db:          db,
-		mongoHelper: NewMongoHelper(db),
-		clock:       clock,
-		counters:    make(map[string]*HealthCheckCounter),
-		client:      NewHTTPClientWithTimeout(10 * time.Second),
-	}
-}
-
-func (m *HealthCheckManager) Start(ctx context.Context) {
-	log.Println("Health check manager started")
-
-	m.loadHealthChecks(ctx)
-
-	go m.reloadHealthChecks(ctx)
-
-	tickChan := m.clock.Subscribe()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-tickChan:
-			m.tick(ctx)
-		}
-	}
-}
-
-func (m *HealthCheckManager) loadHealthChecks(ctx context.Context) {
-	var healthChecks []HealthCheck
-	err := m.mongoHelper.FindActiveDocuments(ctx, "healthchecks", &healthChecks)
-	if err != nil {
-		log.Println("Failed to load health checks:", err)
-		return
-	}
-
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// Create map of active IDs from database
-	activeIDs := make(map[string]bool)
-	for _, hc := range healthChecks {
-		activeIDs[hc.ID.Hex()] = true
-	}
-
-	// Remove health checks that are no longer active or were deleted
-	for id := range m.counters {
-		if !activeIDs[id] {
-			log.Printf("Removing health check: %s (deleted or inactive)", m.counters[id].HealthCheck.Name)
-			delete(m.counters, id)
-		}
-	}
-
-	// Add new or update existing health checks
-	for _, hc := range healthChecks {
-		id := hc.ID.Hex()
-		if counter, exists := m.counters[id]; exists {
-			// Update if configuration changed
-			if counter.HealthCheck.URL != hc.URL ||
-				counter.HealthCheck.Interval != hc.Interval ||
-				counter.HealthCheck.Method != hc.Method ||
-				counter.HealthCheck.StatusCode != hc.StatusCode {
-				log.Printf("Updating health check: %s", hc.Name)
-				counter.HealthCheck = hc
-				counter.Counter = hc.Interval
-			}
-		} else {
-			m.counters[id] = &HealthCheckCounter{
-				HealthCheck: hc,
-				Counter:     hc.Interval,
-			}
-			log.Printf("Loaded health check: %s (interval: %ds)", hc.Name, hc.Interval)
-		}
-	}
-}
-
-func (m *HealthCheckManager) reloadHealthChecks(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			m.loadHealthChecks(ctx)
-		}
-	}
-}
-
-func (m *HealthCheckManager) tick(ctx context.Context) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	for _, counter := range m.counters {
-		counter.Counter--
-
-		if counter.Counter <= 0 {
-			go m.executeHealthCheck(ctx, counter.HealthCheck)
-			counter.Counter = counter.HealthCheck.Interval
-			log.Printf("Executing health check: %s", counter.HealthCheck.Name)
-		}
-	}
-}
-
-func (m *HealthCheckManager) executeHealthCheck(ctx context.Context, hc HealthCheck) {
-	start := time.Now()
-
-	req, err := http.NewRequestWithContext(ctx, hc.Method, hc.URL, nil)
-	if err != nil {
-		m.saveLog(ctx, hc, 0, start, err)
-		return
-	}
-
-	for key, value := range hc.Headers {
-		req.Header.Set(key, value)
-	}
-
-	resp, err := m.client.Do(req)
-	if err != nil {
-		m.saveLog(ctx, hc, 0, start, err)
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		m.saveLog(ctx, hc, resp.StatusCode, start, err)
-		return
-	}
-
-	success := resp.StatusCode == hc.StatusCode
-
-	if hc.ExpectedBody != nil && *hc.ExpectedBody != "" {
-		expectedBody := strings.TrimSpace(*hc.ExpectedBody)
-		actualBody := strings.TrimSpace(string(body))
-		if expectedBody != actualBody {
-			success = false
-		}
-	}
-
-	m.saveLog(ctx, hc, resp.StatusCode, start, nil)
-
-	responseTime := time.Since(start).Milliseconds()
-	if success {
-		log.Printf("[%s] Success - %d in %dms", hc.Name, resp.StatusCode, responseTime)
-	} else {
-		log.Printf("[%s] Failed - expected %d, got %d in %dms", hc.Name, hc.StatusCode, resp.StatusCode, responseTime)
-	}
-}
-
-func (m *HealthCheckManager) saveLog(ctx context.Context, hc HealthCheck, statusCode int, start time.Time, err error) {
-	responseTime := time.Since(start).Milliseconds()
-
-	logEntry := HealthCheckLog{
-		Timestamp:    time.Now(),
-		ResponseTime: responseTime,
-		StatusCode:   statusCode,
-	}
-
-	if err != nil {
-		errMsg := err.Error()
-		logEntry.Error = &errMsg
-		logEntry.Success = false
-	} else {
-		logEntry.Success = statusCode == hc.StatusCode
-	}
-
-	collectionName := fmt.Sprintf("healthcheck_%s", hc.Name)
-	
-	if err := m.mongoHelper.InsertLog(ctx, collectionName, logEntry); err != nil {
-		log.Printf("Failed to save log for %s: %v", hc.Name, err)
-	}
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	CheckTypeHTTP = "http"
+	CheckTypeTCP  = "tcp"
+	CheckTypeDNS  = "dns"
+	CheckTypeGRPC = "grpc"
+
+	CheckTypeHeartbeat = "heartbeat"
+)
+
+type HealthCheck struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         string             `bson:"name"`
+	Tags         []string           `bson:"tags,omitempty"`
+	Type         string             `bson:"type,omitempty"` // http (default), tcp, dns, grpc or heartbeat
+	URL          string             `bson:"url"`
+	Method       string             `bson:"method"`
+	Interval     int                `bson:"interval"`             // seconds
+	IntervalMs   int                `bson:"intervalMs,omitempty"` // sub-second interval, takes precedence over interval
+	Jitter       int                `bson:"jitter,omitempty"`     // ms, random delay added to every run
+	Overlap      string             `bson:"overlap,omitempty"`    // skip (default), queue or allow a run while the previous one is in flight
+	Schedule     string             `bson:"schedule,omitempty"`   // cron expression, takes precedence over interval
+	Timezone     string             `bson:"timezone,omitempty"`   // IANA name the schedule is evaluated in, UTC by default
+	StatusCode   int                `bson:"statusCode"`
+	Headers      map[string]string  `bson:"headers"`
+	ExpectedBody *string            `bson:"expectedBody"`
+	Body         string             `bson:"body,omitempty"`
+	ContentType  string             `bson:"contentType,omitempty"`
+	BodyTemplate bool               `bson:"bodyTemplate,omitempty"` // render {{timestamp}}, {{uuid}}, ... in body before sending
+	Assertions   []Assertion        `bson:"assertions,omitempty"`
+	Status       string             `bson:"status"`
+	CreatedAt    time.Time          `bson:"createdAt"`
+	UpdatedBy    string             `bson:"updatedBy,omitempty"` // whoever saved this config, recorded as the revision author
+	UpdatedAt    time.Time          `bson:"updatedAt,omitempty"` // set together with updatedBy
+	Revision     int                `bson:"-"`                   // config revision, see history.go
+
+	FailureThreshold  int `bson:"failureThreshold,omitempty"`  // consecutive failures before DOWN
+	RecoveryThreshold int `bson:"recoveryThreshold,omitempty"` // consecutive successes before UP
+	FlapThreshold     int `bson:"flapThreshold,omitempty"`     // state changes within flapWindow before FLAPPING
+	FlapWindow        int `bson:"flapWindow,omitempty"`        // seconds
+
+	Notifications []NotificationChannel `bson:"notifications,omitempty"`
+	Retry         *RetryPolicy          `bson:"retry,omitempty"`
+	SLO           *SLOConfig            `bson:"slo,omitempty"`
+	Transport     *TransportOptions     `bson:"transport,omitempty"`
+	Auth          *AuthConfig           `bson:"auth,omitempty"` // applied to the probe and every step
+
+	TLSExpiryWarnDays int `bson:"tlsExpiryWarnDays,omitempty"` // certificates expiring sooner drive the check to DEGRADED
+
+	TCP  *TCPCheckConfig  `bson:"tcp,omitempty"`
+	DNS  *DNSCheckConfig  `bson:"dns,omitempty"`
+	GRPC *GRPCCheckConfig `bson:"grpc,omitempty"`
+
+	Heartbeat *HeartbeatConfig `bson:"heartbeat,omitempty"`
+
+	Steps []HealthCheckStep `bson:"steps,omitempty"` // turns an http check into a multi-step transaction
+}
+
+type HealthCheckLog struct {
+	Timestamp    time.Time         `bson:"timestamp"`
+	StatusCode   int               `bson:"statusCode"`
+	ResponseTime int64             `bson:"responseTime"`
+	Success      bool              `bson:"success"`
+	Error        *string           `bson:"error,omitempty"`
+	ErrorClass   string            `bson:"errorClass,omitempty"`
+	Assertions   []AssertionResult `bson:"assertions,omitempty"`
+	Attempt      int               `bson:"attempt"`
+	Retries      int               `bson:"retries"`
+	Attempts     []AttemptLog      `bson:"attempts,omitempty"` // earlier failed attempts of this probe
+	TLS          *TLSInfo          `bson:"tls,omitempty"`
+	Warnings     []string          `bson:"warnings,omitempty"`
+	ConnectTime  int64             `bson:"connectTime,omitempty"` // ms, tcp checks
+	Banner       string            `bson:"banner,omitempty"`
+	DNS          *DNSResult        `bson:"dns,omitempty"`
+	GRPCStatus   string            `bson:"grpcStatus,omitempty"`
+	Steps        []StepResult      `bson:"steps,omitempty"`
+	Timings      *RequestTimings   `bson:"timings,omitempty"`
+	Heartbeat    *HeartbeatResult  `bson:"heartbeat,omitempty"`
+
+	ConfigRevision int `bson:"configRevision,omitempty"` // revision of the check config that produced this log
+
+	InMaintenance     bool   `bson:"inMaintenance,omitempty"` // excluded from state, notifications and uptime
+	MaintenanceWindow string `bson:"maintenanceWindow,omitempty"`
+}
+
+func (l HealthCheckLog) outcome() string {
+	if l.StatusCode == 0 {
+		return fmt.Sprintf("%dms", l.ResponseTime)
+	}
+	return fmt.Sprintf("%d in %dms", l.StatusCode, l.ResponseTime)
+}
+
+// effectiveAssertions combines the configured assertions with the legacy
+// statusCode and expectedBody fields so older checks keep working.
+func (hc HealthCheck) effectiveAssertions() []Assertion {
+	assertions := make([]Assertion, 0, len(hc.Assertions)+2)
+
+	hasStatusCode := false
+	for _, assertion := range hc.Assertions {
+		if assertion.Type == AssertionStatusCode {
+			hasStatusCode = true
+		}
+	}
+
+	if !hasStatusCode && hc.StatusCode != 0 {
+		assertions = append(assertions, Assertion{
+			Type:     AssertionStatusCode,
+			Operator: OperatorEquals,
+			Value:    strconv.Itoa(hc.StatusCode),
+		})
+	}
+
+	assertions = append(assertions, hc.Assertions...)
+
+	if hc.ExpectedBody != nil && strings.TrimSpace(*hc.ExpectedBody) != "" {
+		assertions = append(assertions, Assertion{
+			Type:     AssertionBody,
+			Operator: OperatorEquals,
+			Value:    *hc.ExpectedBody,
+		})
+	}
+
+	return assertions
+}
+
+type HealthCheckManager struct {
+	db          *mongo.Database
+	mongoHelper *MongoHelper
+	checks      map[string]*ScheduledCheck
+	queue       scheduleQueue
+	wake        chan struct{}
+	jobs        chan healthCheckJob
+	maintenance []MaintenanceWindow
+	workers     int
+	mu          sync.RWMutex
+	client      *http.Client
+	transports  *TransportCache
+	auth        *Authenticator
+	secrets     *SecretStore
+	history     *ConfigHistory
+	notifier    *NotificationDispatcher
+
+	retiredChecks []*ScheduledCheck // removed while burn rate alerts were firing
+}
+
+func NewHealthCheckManager(db *mongo.Database, notifier *NotificationDispatcher, secrets *SecretStore, concurrency int) *HealthCheckManager {
+	if concurrency <= 0 {
+		concurrency = defaultHealthCheckConcurrency
+	}
+
+	mongoHelper := NewMongoHelper(db)
+
+	return &HealthCheckManager{
+		db:          db,
+		mongoHelper: mongoHelper,
+		checks:      make(map[string]*ScheduledCheck),
+		wake:        make(chan struct{}, 1),
+		jobs:        make(chan healthCheckJob, maxQueuedRuns),
+		workers:     concurrency,
+		client:      NewHTTPClientWithTimeout(defaultCheckTimeout),
+		transports:  NewTransportCache(),
+		auth:        NewAuthenticator(),
+		secrets:     secrets,
+		history:     NewConfigHistory(mongoHelper),
+		notifier:    notifier,
+	}
+}
+
+func (m *HealthCheckManager) Start(ctx context.Context) {
+	log.Println("Health check manager started")
+
+	m.loadHealthChecks(ctx)
+	m.loadMaintenanceWindows(ctx)
+	m.restoreBurnRateAlerts(ctx)
+
+	go m.reloadHealthChecks(ctx)
+	go m.reloadMaintenanceWindows(ctx)
+
+	go m.evaluateBurnRatesPeriodically(ctx)
+
+	m.startWorkers(ctx)
+	m.runScheduler(ctx)
+}
+
+func (m *HealthCheckManager) loadHealthChecks(ctx context.Context) {
+	var healthChecks []HealthCheck
+	err := m.mongoHelper.FindActiveDocuments(ctx, "healthchecks", &healthChecks)
+	if err != nil {
+		log.Println("Failed to load health checks:", err)
+		return
+	}
+
+	for i := range healthChecks {
+		m.ensureHeartbeatToken(ctx, &healthChecks[i])
+		m.recordRevision(ctx, &healthChecks[i])
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Create map of active IDs from database
+	activeIDs := make(map[string]bool)
+	for _, hc := range healthChecks {
+		activeIDs[hc.ID.Hex()] = true
+	}
+
+	// Remove health checks that are no longer active or were deleted
+	for id := range m.checks {
+		if !activeIDs[id] {
+			m.removeHealthCheck(id, "deleted or inactive")
+		}
+	}
+
+	// Add new or update existing health checks
+	now := time.Now()
+	for _, hc := range healthChecks {
+		m.applyHealthCheck(hc, now)
+	}
+}
+
+func (m *HealthCheckManager) executeHealthCheck(ctx context.Context, hc HealthCheck) {
+	window := m.activeMaintenance(hc, time.Now())
+
+	logEntry := m.probeWithRetry(ctx, hc)
+	logEntry.ConfigRevision = hc.Revision
+
+	if window != nil {
+		logEntry.InMaintenance = true
+		logEntry.MaintenanceWindow = window.Name
+		m.saveLog(ctx, hc, logEntry)
+		log.Printf("[%s] In maintenance (%s) - %s", hc.Name, window.Name, logEntry.outcome())
+		return
+	}
+
+	m.saveLog(ctx, hc, logEntry)
+	m.updateState(ctx, hc, logEntry)
+
+	if logEntry.Success && len(logEntry.Warnings) > 0 {
+		log.Printf("[%s] Success with warnings - %s: %s", hc.Name, logEntry.outcome(), strings.Join(logEntry.Warnings, "; "))
+	} else if logEntry.Success {
+		log.Printf("[%s] Success - %s", hc.Name, logEntry.outcome())
+	} else {
+		log.Printf("[%s] Failed - %s: %s", hc.Name, logEntry.outcome(), describeFailure(logEntry))
+	}
+}
+
+// probe runs one attempt against a copy of the check with its placeholders
+// resolved; resolved values are masked again in the returned log.
+func (m *HealthCheckManager) probe(ctx context.Context, hc HealthCheck) HealthCheckLog {
+	placeholders := m.secrets.Placeholders(ctx)
+	hc = placeholders.ResolveCheck(hc)
+	if err := placeholders.Err(); err != nil {
+		return newConfigErrorLog(time.Now(), err)
+	}
+
+	logEntry := m.probeType(ctx, hc)
+	placeholders.MaskLog(&logEntry)
+
+	return logEntry
+}
+
+func (m *HealthCheckManager) probeType(ctx context.Context, hc HealthCheck) HealthCheckLog {
+	switch hc.Type {
+	case "", CheckTypeHTTP:
+		if len(hc.Steps) > 0 {
+			return m.probeSteps(ctx, hc)
+		}
+		return m.probeHTTP(ctx, hc)
+	case CheckTypeTCP:
+		return m.probeTCP(ctx, hc)
+	case CheckTypeDNS:
+		return m.probeDNS(ctx, hc)
+	case CheckTypeGRPC:
+		return m.probeGRPC(ctx, hc)
+	case CheckTypeHeartbeat:
+		return m.probeHeartbeat(ctx, hc)
+	default:
+		return newFailedLog(time.Now(), 0, fmt.Errorf("unknown check type '%s'", hc.Type))
+	}
+}
+
+func (m *HealthCheckManager) probeHTTP(ctx context.Context, hc HealthCheck) HealthCheckLog {
+	start := time.Now()
+
+	body := hc.Body
+	if hc.BodyTemplate {
+		var missing []string
+		body, missing = RenderTemplate(body, builtinVariables(hc, start))
+		if len(missing) > 0 {
+			return newFailedLog(start, 0, fmt.Errorf("undefined body variables: %s", strings.Join(missing, ", ")))
+		}
+	}
+
+	client, roots, err := m.httpClient(hc)
+	if err != nil {
+		return newFailedLog(start, 0, err)
+	}
+
+	resp, err := m.sendRequest(ctx, client, probeRequest{
+		Method:      hc.Method,
+		URL:         hc.URL,
+		Headers:     hc.Headers,
+		Body:        body,
+		ContentType: hc.ContentType,
+		Auth:        hc.Auth,
+	})
+	if err != nil {
+		logEntry := newFailedLog(start, resp.StatusCode, err)
+		logEntry.Timings = resp.Timings
+		return logEntry
+	}
+
+	results := EvaluateAssertions(hc.effectiveAssertions(), resp)
+
+	logEntry := HealthCheckLog{
+		Timestamp:    time.Now(),
+		StatusCode:   resp.StatusCode,
+		ResponseTime: time.Since(start).Milliseconds(),
+		Success:      AssertionsPassed(results),
+		Assertions:   results,
+		TLS:          InspectTLS(resp.TLS, hostFromTarget(hc.URL), roots, start),
+		Timings:      resp.Timings,
+	}
+
+	if logEntry.Success {
+		applyTLSRules(hc, &logEntry, start)
+	}
+
+	return logEntry
+}
+
+type probeRequest struct {
+	Method      string
+	URL         string
+	Headers     map[string]string
+	Body        string
+	ContentType string
+	Auth        *AuthConfig
+}
+
+// sendRequest performs a single HTTP round trip and reads the whole body.
+// The returned snapshot carries the status code and phase timings even when
+// the request fails.
+func (m *HealthCheckManager) sendRequest(ctx context.Context, client *http.Client, pr probeRequest) (ResponseSnapshot, error) {
+	tracer, ctx := newRequestTracer(ctx)
+
+	var bodyReader io.Reader
+	if pr.Body != "" {
+		bodyReader = strings.NewReader(pr.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, pr.Method, pr.URL, bodyReader)
+	if err != nil {
+		return ResponseSnapshot{}, err
+	}
+
+	// Explicit headers win over the auth block, e.g. a step's own bearer token
+	if err := m.auth.Apply(ctx, client, req, pr.Auth); err != nil {
+		return ResponseSnapshot{}, err
+	}
+	if pr.ContentType != "" {
+		req.Header.Set("Content-Type", pr.ContentType)
+	}
+	for key, value := range pr.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		timings := tracer.Timings(time.Now())
+		return ResponseSnapshot{Timings: &timings}, err
+	}
+	defer resp.Body.Close()
+
+	// A revoked token is fetched again on the next run instead of at expiry
+	if resp.StatusCode == http.StatusUnauthorized {
+		m.auth.Invalidate(pr.Auth)
+	}
+
+	snapshot := ResponseSnapshot{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		TLS:        resp.TLS,
+	}
+
+	snapshot.Body, err = io.ReadAll(resp.Body)
+
+	timings := tracer.Timings(time.Now())
+	snapshot.Timings = &timings
+
+	return snapshot, err
+}
+
+func newFailedLog(start time.Time, statusCode int, err error) HealthCheckLog {
+	errMsg := err.Error()
+	return HealthCheckLog{
+		Timestamp:    time.Now(),
+		StatusCode:   statusCode,
+		ResponseTime: time.Since(start).Milliseconds(),
+		Success:      false,
+		Error:        &errMsg,
+		ErrorClass:   classifyError(err),
+	}
+}
+
+// newConfigErrorLog fails a run because of the check's configuration
+// rather than the target
+func newConfigErrorLog(start time.Time, err error) HealthCheckLog {
+	logEntry := newFailedLog(start, 0, err)
+	logEntry.ErrorClass = ErrorClassConfig
+	return logEntry
+}
+
+func healthCheckCollection(name string) string {
+	return fmt.Sprintf("healthcheck_%s", name)
+}
+
+func (m *HealthCheckManager) saveLog(ctx context.Context, hc HealthCheck, logEntry HealthCheckLog) {
+	collectionName := healthCheckCollection(hc.Name)
+	
+	if err := m.mongoHelper.InsertLog(ctx, collectionName, logEntry); err != nil {
+		log.Printf("Failed to save log for %s: %v", hc.Name, err)
+	}
 }
\ No newline at end of file
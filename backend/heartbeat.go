@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	heartbeatPingsCollection = "heartbeat_pings"
+
+	PingSuccess = "success"
+	PingStart   = "start"
+	PingFail    = "fail"
+
+	// How often a heartbeat check is evaluated when it has no interval
+	defaultHeartbeatEvaluation = 30 * time.Second
+	maxPingMessageSize         = 10 * 1024
+)
+
+// HeartbeatConfig makes a check passive: jobs report in via /ping/<token>
+// instead of being probed.
+type HeartbeatConfig struct {
+	Token  string `bson:"token,omitempty"` // generated on first load when empty
+	Period int    `bson:"period"`          // seconds, a ping is expected at least this often
+	Grace  int    `bson:"grace,omitempty"` // seconds of slack after the period; also the longest run between start and finish
+}
+
+type HeartbeatPing struct {
+	CheckID    primitive.ObjectID `bson:"checkId"`
+	CheckName  string             `bson:"checkName"`
+	Kind       string             `bson:"kind"`               // success, start or fail
+	Duration   int64              `bson:"duration,omitempty"` // ms since the start ping
+	Message    string             `bson:"message,omitempty"`  // request body, e.g. job output
+	RemoteAddr string             `bson:"remoteAddr,omitempty"`
+	UserAgent  string             `bson:"userAgent,omitempty"`
+	Timestamp  time.Time          `bson:"timestamp"`
+}
+
+type HeartbeatResult struct {
+	LastPing time.Time `bson:"lastPing,omitempty"`
+	LastKind string    `bson:"lastKind,omitempty"`
+	Started  time.Time `bson:"started,omitempty"`  // pending start ping
+	Duration int64     `bson:"duration,omitempty"` // ms, last run with a start ping
+	Message  string    `bson:"message,omitempty"`
+}
+
+// heartbeatState is the last check-in of a heartbeat check, restored from
+// heartbeat_pings the first time it is needed.
+type heartbeatState struct {
+	mu sync.Mutex
+
+	restored bool
+	since    time.Time // checks that never pinged are measured from here
+	lastPing time.Time // last success or fail
+	lastKind string
+	started  time.Time
+	duration time.Duration
+	message  string
+}
+
+func newHeartbeatState(now time.Time) *heartbeatState {
+	return &heartbeatState{since: now}
+}
+
+func (c HeartbeatConfig) period() time.Duration {
+	return time.Duration(c.Period) * time.Second
+}
+
+func (c HeartbeatConfig) grace() time.Duration {
+	return time.Duration(c.Grace) * time.Second
+}
+
+// maxRunTime bounds the time between a start ping and its finish
+func (c HeartbeatConfig) maxRunTime() time.Duration {
+	if c.Grace > 0 {
+		return c.grace()
+	}
+	return c.period()
+}
+
+func newHeartbeatToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// ensureHeartbeatToken gives a heartbeat check without a token a random one
+// and stores it, so the ping URL stays stable across restarts.
+func (m *HealthCheckManager) ensureHeartbeatToken(ctx context.Context, hc *HealthCheck) {
+	if hc.Type != CheckTypeHeartbeat || (hc.Heartbeat != nil && hc.Heartbeat.Token != "") {
+		return
+	}
+
+	token, err := newHeartbeatToken()
+	if err != nil {
+		log.Printf("[%s] Failed to generate heartbeat token: %v", hc.Name, err)
+		return
+	}
+
+	_, err = m.mongoHelper.GetCollection("healthchecks").UpdateOne(ctx,
+		bson.M{"_id": hc.ID}, bson.M{"$set": bson.M{"heartbeat.token": token}})
+	if err != nil {
+		log.Printf("[%s] Failed to save heartbeat token: %v", hc.Name, err)
+		return
+	}
+
+	if hc.Heartbeat == nil {
+		hc.Heartbeat = &HeartbeatConfig{}
+	}
+	hc.Heartbeat.Token = token
+	log.Printf("[%s] Heartbeat ping URL: /ping/%s", hc.Name, token)
+}
+
+func (m *HealthCheckManager) findHeartbeat(token string) *ScheduledCheck {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, check := range m.checks {
+		hc := check.HealthCheck
+		if hc.Type == CheckTypeHeartbeat && hc.Heartbeat != nil && hc.Heartbeat.Token == token {
+			return check
+		}
+	}
+	return nil
+}
+
+// restore loads the last pings once; callers hold s.mu
+func (m *HealthCheckManager) restoreHeartbeat(ctx context.Context, hc HealthCheck, s *heartbeatState) {
+	if s.restored {
+		return
+	}
+
+	collection := m.mongoHelper.GetCollection(heartbeatPingsCollection)
+	latest := options.FindOne().SetSort(bson.M{"timestamp": -1})
+
+	var last HeartbeatPing
+	err := collection.FindOne(ctx, bson.M{"checkId": hc.ID, "kind": bson.M{"$ne": PingStart}}, latest).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("[%s] Failed to restore heartbeat: %v", hc.Name, err)
+		return
+	}
+	if err == nil && last.Timestamp.After(s.lastPing) {
+		s.lastPing = last.Timestamp
+		s.lastKind = last.Kind
+		s.duration = time.Duration(last.Duration) * time.Millisecond
+		s.message = last.Message
+	}
+
+	var start HeartbeatPing
+	err = collection.FindOne(ctx, bson.M{
+		"checkId":   hc.ID,
+		"kind":      PingStart,
+		"timestamp": bson.M{"$gt": s.lastPing},
+	}, latest).Decode(&start)
+	if err == nil && start.Timestamp.After(s.started) {
+		s.started = start.Timestamp
+	} else if err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("[%s] Failed to restore heartbeat: %v", hc.Name, err)
+		return
+	}
+
+	s.restored = true
+}
+
+// record applies a ping; callers hold s.mu
+func (s *heartbeatState) record(kind, message string, now time.Time) time.Duration {
+	if kind == PingStart {
+		s.started = now
+		return 0
+	}
+
+	var duration time.Duration
+	if !s.started.IsZero() {
+		duration = now.Sub(s.started)
+	}
+
+	s.lastPing = now
+	s.lastKind = kind
+	s.started = time.Time{}
+	s.duration = duration
+	s.message = message
+
+	return duration
+}
+
+// evaluate turns the last check-in into a probe result; callers hold s.mu
+func (s *heartbeatState) evaluate(config HeartbeatConfig, now time.Time) HealthCheckLog {
+	result := &HeartbeatResult{
+		LastPing: s.lastPing,
+		LastKind: s.lastKind,
+		Started:  s.started,
+		Duration: s.duration.Milliseconds(),
+		Message:  truncate(s.message, 200),
+	}
+
+	fail := func(err error) HealthCheckLog {
+		logEntry := newFailedLog(now, 0, err)
+		logEntry.ResponseTime = result.Duration
+		logEntry.Heartbeat = result
+		return logEntry
+	}
+
+	if config.Period <= 0 {
+		return fail(fmt.Errorf("heartbeat.period is required"))
+	}
+
+	if !s.started.IsZero() && now.Sub(s.started) > config.maxRunTime() {
+		return fail(fmt.Errorf("job started %s ago and has not finished (limit %s)",
+			now.Sub(s.started).Round(time.Second), config.maxRunTime()))
+	}
+
+	if s.lastKind == PingFail {
+		if s.message != "" {
+			return fail(fmt.Errorf("job reported failure: %s", truncate(s.message, 200)))
+		}
+		return fail(fmt.Errorf("job reported failure"))
+	}
+
+	since := s.lastPing
+	if since.IsZero() {
+		since = s.since
+	}
+	if now.Sub(since) > config.period()+config.grace() {
+		if s.lastPing.IsZero() {
+			return fail(fmt.Errorf("no ping received (expected every %s + %s grace)", config.period(), config.grace()))
+		}
+		return fail(fmt.Errorf("no ping for %s (expected every %s + %s grace)",
+			now.Sub(s.lastPing).Round(time.Second), config.period(), config.grace()))
+	}
+
+	return HealthCheckLog{
+		Timestamp:    now,
+		ResponseTime: result.Duration,
+		Success:      true,
+		Heartbeat:    result,
+	}
+}
+
+func (m *HealthCheckManager) probeHeartbeat(ctx context.Context, hc HealthCheck) HealthCheckLog {
+	now := time.Now()
+
+	m.mu.RLock()
+	check := m.checks[hc.ID.Hex()]
+	m.mu.RUnlock()
+	if check == nil {
+		return newFailedLog(now, 0, fmt.Errorf("heartbeat check is not loaded"))
+	}
+
+	var config HeartbeatConfig
+	if hc.Heartbeat != nil {
+		config = *hc.Heartbeat
+	}
+
+	state := check.heartbeat
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	m.restoreHeartbeat(ctx, hc, state)
+	return state.evaluate(config, now)
+}
+
+// HandlePing serves /ping/{token} (success, also finishes a started run),
+// /ping/{token}/start and /ping/{token}/fail. The request body, if any, is
+// kept as the ping message.
+func (m *HealthCheckManager) HandlePing(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodPost, http.MethodHead:
+	default:
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kind := r.PathValue("kind")
+	switch kind {
+	case "":
+		kind = PingSuccess
+	case PingStart, PingFail:
+	default:
+		JSONError(w, "Unknown ping kind", http.StatusNotFound)
+		return
+	}
+
+	check := m.findHeartbeat(r.PathValue("token"))
+	if check == nil {
+		JSONError(w, "Unknown ping token", http.StatusNotFound)
+		return
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxPingMessageSize))
+	message := strings.TrimSpace(string(body))
+
+	m.mu.RLock()
+	hc := check.HealthCheck
+	m.mu.RUnlock()
+
+	now := time.Now()
+	state := check.heartbeat
+	state.mu.Lock()
+	m.restoreHeartbeat(r.Context(), hc, state)
+	duration := state.record(kind, message, now)
+	state.mu.Unlock()
+
+	ping := HeartbeatPing{
+		CheckID:    hc.ID,
+		CheckName:  hc.Name,
+		Kind:       kind,
+		Duration:   duration.Milliseconds(),
+		Message:    message,
+		RemoteAddr: r.RemoteAddr,
+		UserAgent:  r.UserAgent(),
+		Timestamp:  now,
+	}
+	if err := m.mongoHelper.InsertLog(r.Context(), heartbeatPingsCollection, ping); err != nil {
+		log.Printf("[%s] Failed to save ping: %v", hc.Name, err)
+	}
+
+	// Finished and failed runs are evaluated right away instead of at the
+	// next interval
+	if kind != PingStart {
+		m.mu.Lock()
+		m.dispatch(check, now)
+		m.mu.Unlock()
+	}
+
+	JSONResponse(w, map[string]string{"status": "ok", "kind": kind}, http.StatusOK)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatEvaluate(t *testing.T) {
+	now := schedulerTestNow
+	config := HeartbeatConfig{Period: 60, Grace: 30}
+
+	tests := []struct {
+		name   string
+		config HeartbeatConfig
+		state  *heartbeatState
+		err    string
+	}{
+		{"no period", HeartbeatConfig{}, &heartbeatState{since: now}, "heartbeat.period is required"},
+		{"never pinged, within grace", config, &heartbeatState{since: now.Add(-90 * time.Second)}, ""},
+		{"never pinged", config, &heartbeatState{since: now.Add(-91 * time.Second)}, "no ping received (expected every 1m0s + 30s grace)"},
+		{"recent ping", config, &heartbeatState{since: now.Add(-time.Hour), lastPing: now.Add(-80 * time.Second), lastKind: PingSuccess}, ""},
+		{"late ping", config, &heartbeatState{since: now.Add(-time.Hour), lastPing: now.Add(-2 * time.Minute), lastKind: PingSuccess}, "no ping for 2m0s (expected every 1m0s + 30s grace)"},
+		{"reported failure", config, &heartbeatState{lastPing: now, lastKind: PingFail, message: "exit status 1"}, "job reported failure: exit status 1"},
+		{"reported failure without output", config, &heartbeatState{lastPing: now, lastKind: PingFail}, "job reported failure"},
+		{"running within the grace", config, &heartbeatState{lastPing: now, lastKind: PingSuccess, started: now.Add(-30 * time.Second)}, ""},
+		{"running too long", config, &heartbeatState{lastPing: now, lastKind: PingSuccess, started: now.Add(-31 * time.Second)}, "job started 31s ago and has not finished (limit 30s)"},
+		{"running too long without grace", HeartbeatConfig{Period: 60}, &heartbeatState{lastPing: now, lastKind: PingSuccess, started: now.Add(-61 * time.Second)}, "(limit 1m0s)"},
+	}
+
+	for _, test := range tests {
+		logEntry := test.state.evaluate(test.config, now)
+		if logEntry.Heartbeat == nil {
+			t.Errorf("%s: no heartbeat result", test.name)
+			continue
+		}
+		if test.err == "" {
+			if !logEntry.Success {
+				t.Errorf("%s: failed with %s", test.name, *logEntry.Error)
+			}
+			continue
+		}
+		if logEntry.Success || !strings.Contains(*logEntry.Error, test.err) {
+			t.Errorf("%s: success = %v, error = %v, want %q", test.name, logEntry.Success, logEntry.Error, test.err)
+		}
+	}
+}
+
+func TestHeartbeatRecord(t *testing.T) {
+	state := newHeartbeatState(schedulerTestNow)
+
+	if duration := state.record(PingStart, "", schedulerTestNow); duration != 0 || !state.started.Equal(schedulerTestNow) {
+		t.Fatalf("start: duration = %v, started = %v", duration, state.started)
+	}
+
+	finished := schedulerTestNow.Add(42 * time.Second)
+	if duration := state.record(PingSuccess, "done", finished); duration != 42*time.Second {
+		t.Errorf("duration = %v", duration)
+	}
+	if !state.started.IsZero() || !state.lastPing.Equal(finished) || state.lastKind != PingSuccess || state.message != "done" {
+		t.Errorf("state = %+v", state)
+	}
+
+	logEntry := state.evaluate(HeartbeatConfig{Period: 60}, finished)
+	if !logEntry.Success || logEntry.ResponseTime != 42000 || logEntry.Heartbeat.Message != "done" {
+		t.Errorf("log = %+v", logEntry)
+	}
+}
+
+func TestHeartbeatPeriod(t *testing.T) {
+	tests := []struct {
+		hc   HealthCheck
+		want time.Duration
+	}{
+		{HealthCheck{Type: CheckTypeHeartbeat}, defaultHeartbeatEvaluation},
+		{HealthCheck{Type: CheckTypeHeartbeat, Interval: 10}, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		if got := test.hc.period(); got != test.want {
+			t.Errorf("period(%+v) = %v, want %v", test.hc, got, test.want)
+		}
+	}
+}
+
+func TestHandlePing(t *testing.T) {
+	m := newTestWorkerManager(10)
+	m.mongoHelper = NewMongoHelper(newTestDatabase(t))
+
+	check := newScheduledCheck(HealthCheck{Name: "backup", Type: CheckTypeHeartbeat, Heartbeat: &HeartbeatConfig{Token: "abc123", Period: 3600}}, time.Now())
+	check.heartbeat.restored = true
+	m.checks["backup"] = check
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping/{token}", m.HandlePing)
+	mux.HandleFunc("/ping/{token}/{kind}", m.HandlePing)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		jobs   int
+	}{
+		{http.MethodPost, "/ping/abc123/start", "", http.StatusOK, 0},
+		{http.MethodPost, "/ping/abc123/fail", "disk full", http.StatusOK, 1},
+		{http.MethodGet, "/ping/abc123/finish", "", http.StatusNotFound, 1},
+		{http.MethodGet, "/ping/unknown", "", http.StatusNotFound, 1},
+		{http.MethodDelete, "/ping/abc123", "", http.StatusMethodNotAllowed, 1},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		if w.Code != test.status || len(m.jobs) != test.jobs {
+			t.Errorf("%s %s: status = %d, jobs = %d", test.method, test.path, w.Code, len(m.jobs))
+		}
+	}
+
+	if check.heartbeat.lastKind != PingFail || check.heartbeat.message != "disk full" || check.heartbeat.duration <= 0 {
+		t.Errorf("state = %+v", check.heartbeat)
+	}
+}
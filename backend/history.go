@@ -0,0 +1,560 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	revisionsCollection = "healthcheck_revisions"
+	unknownAuthor       = "unknown"
+	rollbackAuthor      = "api" // the API has no user sessions to take an author from
+	maxListedRevisions  = 100
+)
+
+// HealthCheckRevision is one stored version of a check. Credentials
+// (sensitive headers, auth secrets, notification keys, the transport's
+// client key, the heartbeat token) are masked in Config, Before and After,
+// see Redactor.Config; a rollback takes them from the live document.
+type HealthCheckRevision struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	CheckID      primitive.ObjectID `bson:"checkId"`
+	CheckName    string             `bson:"checkName"`
+	Revision     int                `bson:"revision"`
+	Author       string             `bson:"author"`
+	Timestamp    time.Time          `bson:"timestamp"`
+	RolledBackTo int                `bson:"rolledBackTo,omitempty"` // set on revisions created by a rollback
+	Changed      []string           `bson:"changed,omitempty"`      // empty for the first revision
+	Before       bson.M             `bson:"before,omitempty"`       // changed fields only
+	After        bson.M             `bson:"after,omitempty"`
+	Config       HealthCheck        `bson:"config"`
+}
+
+// ConfigHistory records a revision whenever the stored config of a check
+// differs from its latest revision. The latest revision per check is kept
+// in memory so reloads only hit the database when something changed.
+type ConfigHistory struct {
+	mongoHelper *MongoHelper
+
+	mu     sync.Mutex
+	latest map[string]*HealthCheckRevision // as stored, i.e. redacted
+	seen   map[string]HealthCheck          // last config recorded in this process
+}
+
+func NewConfigHistory(mongoHelper *MongoHelper) *ConfigHistory {
+	return &ConfigHistory{
+		mongoHelper: mongoHelper,
+		latest:      make(map[string]*HealthCheckRevision),
+		seen:        make(map[string]HealthCheck),
+	}
+}
+
+func (h *ConfigHistory) latestRevision(ctx context.Context, checkID primitive.ObjectID) (*HealthCheckRevision, error) {
+	if revision, ok := h.latest[checkID.Hex()]; ok {
+		return revision, nil
+	}
+
+	var revision HealthCheckRevision
+	err := h.mongoHelper.GetCollection(revisionsCollection).FindOne(ctx,
+		bson.M{"checkId": checkID},
+		options.FindOne().SetSort(bson.M{"revision": -1})).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error loading latest revision: %w", err)
+	}
+
+	h.latest[checkID.Hex()] = &revision
+	return &revision, nil
+}
+
+// Record stores a new revision if hc changed and sets hc.Revision. Without
+// an author, the one stored on the document is used if it is current.
+func (h *ConfigHistory) Record(ctx context.Context, hc *HealthCheck, author string, rolledBackTo int) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.record(ctx, hc, author, rolledBackTo)
+}
+
+// record is Record for callers holding h.mu
+func (h *ConfigHistory) record(ctx context.Context, hc *HealthCheck, author string, rolledBackTo int) error {
+	latest, err := h.latestRevision(ctx, hc.ID)
+	if err != nil {
+		return err
+	}
+
+	// The stored config is redacted; compare in the same form
+	current := h.mongoHelper.redactor.Config(*hc)
+
+	revision := HealthCheckRevision{
+		ID:           primitive.NewObjectID(),
+		CheckID:      hc.ID,
+		CheckName:    hc.Name,
+		Revision:     1,
+		Author:       author,
+		Timestamp:    time.Now(),
+		RolledBackTo: rolledBackTo,
+		Config:       *hc,
+	}
+	if revision.Author == "" {
+		revision.Author = documentAuthor(latest, *hc)
+	}
+
+	if latest != nil {
+		// Stored revisions can't tell a rotated credential from the old one,
+		// the config seen last can
+		if seen, ok := h.seen[hc.ID.Hex()]; ok {
+			revision.Changed = diffHealthChecks(seen, *hc)
+		} else {
+			revision.Changed = diffHealthChecks(latest.Config, current)
+		}
+		if len(revision.Changed) == 0 {
+			h.seen[hc.ID.Hex()] = *hc
+			hc.Revision = latest.Revision
+			return nil
+		}
+		revision.Revision = latest.Revision + 1
+		revision.Before = healthCheckFields(latest.Config, revision.Changed)
+		revision.After = healthCheckFields(current, revision.Changed)
+	}
+
+	if err := h.mongoHelper.InsertLog(ctx, revisionsCollection, revision); err != nil {
+		return err
+	}
+
+	revision.Config = current
+	h.latest[hc.ID.Hex()] = &revision
+	h.seen[hc.ID.Hex()] = *hc
+	hc.Revision = revision.Revision
+
+	log.Printf("[%s] Recorded config revision %d by %s", hc.Name, revision.Revision, revision.Author)
+	return nil
+}
+
+// documentAuthor trusts updatedBy only if updatedAt moved since the last
+// revision; edits that bypass the portal and the API leave a stale author
+// behind that must not be blamed for them.
+func documentAuthor(latest *HealthCheckRevision, hc HealthCheck) string {
+	if hc.UpdatedBy == "" {
+		return unknownAuthor
+	}
+	if latest != nil && !hc.UpdatedAt.After(latest.Config.UpdatedAt) {
+		return unknownAuthor
+	}
+	return hc.UpdatedBy
+}
+
+// healthCheckFields picks the named bson fields of a check
+func healthCheckFields(hc HealthCheck, names []string) bson.M {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	fields := bson.M{}
+	value := reflect.ValueOf(hc)
+	for i := 0; i < value.NumField(); i++ {
+		name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("bson"), ",")
+		if wanted[name] {
+			fields[name] = value.Field(i).Interface()
+		}
+	}
+	return fields
+}
+
+// rollbackKeptFields are never taken from a revision
+var rollbackKeptFields = map[string]bool{
+	"_id":       true,
+	"name":      true,
+	"status":    true,
+	"createdAt": true,
+	"updatedBy": true,
+	"updatedAt": true,
+}
+
+// configTree returns the bson form of a document as nested bson.M and bson.A
+func configTree(document interface{}) (bson.M, error) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding config: %w", err)
+	}
+
+	var tree bson.M
+	if err := bson.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("error decoding config: %w", err)
+	}
+	return tree, nil
+}
+
+// restoreMasked replaces what redaction masked in a revision's value with
+// the live value it stood for. A part of the live config that redacts to
+// exactly the revision's part is taken as is, so credentials come from the
+// live document and never from the revision; masked values that can't be
+// matched that way are an error rather than being written back masked.
+func restoreMasked(target, current, currentRedacted interface{}, path string) (interface{}, error) {
+	if current != nil && reflect.DeepEqual(target, currentRedacted) {
+		return current, nil
+	}
+
+	switch value := target.(type) {
+	case string:
+		if strings.Contains(value, redactedValue) {
+			return nil, fmt.Errorf("%s is redacted in this revision and differs from the current config", path)
+		}
+	case bson.M:
+		currentDoc, _ := current.(bson.M)
+		redactedDoc, _ := currentRedacted.(bson.M)
+
+		restored := make(bson.M, len(value))
+		for key, child := range value {
+			restoredChild, err := restoreMasked(child, currentDoc[key], redactedDoc[key], path+"."+key)
+			if err != nil {
+				return nil, err
+			}
+			restored[key] = restoredChild
+		}
+		return restored, nil
+	case bson.A:
+		currentArray, _ := current.(bson.A)
+		redactedArray, _ := currentRedacted.(bson.A)
+
+		restored := make(bson.A, len(value))
+		for i, child := range value {
+			// An element that only differs in masked values is matched
+			// wherever it moved to, anything else by position
+			match := -1
+			for j := range redactedArray {
+				if reflect.DeepEqual(child, redactedArray[j]) {
+					match = j
+					break
+				}
+			}
+			if match == -1 && i < len(currentArray) && i < len(redactedArray) {
+				match = i
+			}
+
+			var currentChild, redactedChild interface{}
+			if match != -1 {
+				currentChild, redactedChild = currentArray[match], redactedArray[match]
+			}
+			restoredChild, err := restoreMasked(child, currentChild, redactedChild, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			restored[i] = restoredChild
+		}
+		return restored, nil
+	}
+
+	return target, nil
+}
+
+// rollbackUpdate computes the $set and $unset that turn the current check
+// into the revision's config. Only fields of HealthCheck are touched, so
+// anything else stored on the document survives a rollback.
+func (h *ConfigHistory) rollbackUpdate(current, target HealthCheck) (set, unset bson.M, changed []string, err error) {
+	currentTree, err := configTree(current)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	redactedTree, err := configTree(h.mongoHelper.redactor.Config(current))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	targetTree, err := configTree(target)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	set, unset = bson.M{}, bson.M{}
+	healthCheckType := reflect.TypeOf(HealthCheck{})
+	for i := 0; i < healthCheckType.NumField(); i++ {
+		name, _, _ := strings.Cut(healthCheckType.Field(i).Tag.Get("bson"), ",")
+		if name == "" || name == "-" || rollbackKeptFields[name] {
+			continue
+		}
+
+		targetValue, inTarget := targetTree[name]
+		restored, err := restoreMasked(targetValue, currentTree[name], redactedTree[name], name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if reflect.DeepEqual(restored, currentTree[name]) {
+			continue
+		}
+
+		changed = append(changed, name)
+		if inTarget {
+			set[name] = restored
+		} else {
+			unset[name] = ""
+		}
+	}
+
+	return set, unset, changed, nil
+}
+
+// applyUpdate returns the check as it reads back after set and unset
+func applyUpdate(hc HealthCheck, set, unset bson.M) (HealthCheck, error) {
+	tree, err := configTree(hc)
+	if err != nil {
+		return hc, err
+	}
+	for name, value := range set {
+		tree[name] = value
+	}
+	for name := range unset {
+		delete(tree, name)
+	}
+
+	data, err := bson.Marshal(tree)
+	if err != nil {
+		return hc, fmt.Errorf("error encoding config: %w", err)
+	}
+	var updated HealthCheck
+	if err := bson.Unmarshal(data, &updated); err != nil {
+		return hc, fmt.Errorf("error decoding config: %w", err)
+	}
+	return updated, nil
+}
+
+// redactedResponse masks a stored revision again before it is returned;
+// revisions written before a header was added to REDACT_HEADERS may still
+// hold it in plain text. Before and after keep only their changed fields.
+func (m *HealthCheckManager) redactedResponse(revision bson.M) (bson.M, error) {
+	for _, key := range []string{"config", "before", "after"} {
+		part, ok := revision[key]
+		if !ok {
+			continue
+		}
+		partTree, err := configTree(part)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := bson.Marshal(partTree)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding config: %w", err)
+		}
+		var hc HealthCheck
+		if err := bson.Unmarshal(data, &hc); err != nil {
+			return nil, fmt.Errorf("error decoding config: %w", err)
+		}
+		redactedTree, err := configTree(m.mongoHelper.redactor.Config(hc))
+		if err != nil {
+			return nil, err
+		}
+
+		for name := range partTree {
+			if value, ok := redactedTree[name]; ok {
+				partTree[name] = value
+			}
+		}
+		revision[key] = partTree
+	}
+	return revision, nil
+}
+
+// recordRevision tags hc with its revision; a failure to store one is
+// only logged
+func (m *HealthCheckManager) recordRevision(ctx context.Context, hc *HealthCheck) {
+	if err := m.history.Record(ctx, hc, "", 0); err != nil {
+		log.Printf("[%s] Failed to record config revision: %v", hc.Name, err)
+	}
+}
+
+func (m *HealthCheckManager) findHealthCheck(ctx context.Context, name string) (*HealthCheck, error) {
+	var hc HealthCheck
+	err := m.mongoHelper.GetCollection("healthchecks").FindOne(ctx, bson.M{"name": name}).Decode(&hc)
+	if err != nil {
+		return nil, err
+	}
+	return &hc, nil
+}
+
+// HandleRevisions serves GET /healthchecks/{name}/revisions, newest first
+// and without the full configs
+func (m *HealthCheckManager) HandleRevisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+	hc, err := m.findHealthCheck(r.Context(), name)
+	if err == mongo.ErrNoDocuments {
+		JSONError(w, fmt.Sprintf("Health check '%s' not found", name), http.StatusNotFound)
+		return
+	} else if err != nil {
+		JSONError(w, "Error loading health check", http.StatusInternalServerError)
+		return
+	}
+
+	cursor, err := m.mongoHelper.GetCollection(revisionsCollection).Find(r.Context(),
+		bson.M{"checkId": hc.ID},
+		options.Find().
+			SetSort(bson.M{"revision": -1}).
+			SetLimit(maxListedRevisions).
+			SetProjection(bson.M{"config": 0}))
+	if err != nil {
+		JSONError(w, "Error loading revisions", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(r.Context())
+
+	revisions := []bson.M{}
+	if err := cursor.All(r.Context(), &revisions); err != nil {
+		JSONError(w, "Error decoding revisions", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range revisions {
+		if revisions[i], err = m.redactedResponse(revisions[i]); err != nil {
+			JSONError(w, "Error redacting revisions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	JSONResponse(w, revisions, http.StatusOK)
+}
+
+// HandleRevision serves GET /healthchecks/{name}/revisions/{revision}
+// including the full config
+func (m *HealthCheckManager) HandleRevision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hc, number, ok := m.revisionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var revision bson.M
+	err := m.mongoHelper.GetCollection(revisionsCollection).FindOne(r.Context(),
+		bson.M{"checkId": hc.ID, "revision": number}).Decode(&revision)
+	if err == mongo.ErrNoDocuments {
+		JSONError(w, fmt.Sprintf("Revision %d not found", number), http.StatusNotFound)
+		return
+	} else if err != nil {
+		JSONError(w, "Error loading revision", http.StatusInternalServerError)
+		return
+	}
+
+	revision, err = m.redactedResponse(revision)
+	if err != nil {
+		JSONError(w, "Error redacting revision", http.StatusInternalServerError)
+		return
+	}
+
+	JSONResponse(w, revision, http.StatusOK)
+}
+
+// HandleRollback serves POST /healthchecks/{name}/revisions/{revision}/rollback.
+// The old config is written back as a new revision by rollbackAuthor.
+func (m *HealthCheckManager) HandleRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	current, number, ok := m.revisionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var target HealthCheckRevision
+	err := m.mongoHelper.GetCollection(revisionsCollection).FindOne(r.Context(),
+		bson.M{"checkId": current.ID, "revision": number}).Decode(&target)
+	if err == mongo.ErrNoDocuments {
+		JSONError(w, fmt.Sprintf("Revision %d not found", number), http.StatusNotFound)
+		return
+	} else if err != nil {
+		JSONError(w, "Error loading revision", http.StatusInternalServerError)
+		return
+	}
+
+	set, unset, changed, err := m.history.rollbackUpdate(*current, target.Config)
+	if err != nil {
+		JSONError(w, fmt.Sprintf("Cannot roll back to revision %d: %v", number, err), http.StatusConflict)
+		return
+	}
+	if len(changed) == 0 {
+		JSONError(w, fmt.Sprintf("Revision %d matches the current config", number), http.StatusConflict)
+		return
+	}
+
+	set["updatedBy"] = rollbackAuthor
+	set["updatedAt"] = time.Now()
+	restored, err := applyUpdate(*current, set, unset)
+	if err != nil {
+		JSONError(w, "Error applying revision", http.StatusInternalServerError)
+		return
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	// Holding the history lock until the revision is recorded keeps the
+	// change stream from recording the same change without the rollback note
+	m.history.mu.Lock()
+	_, err = m.mongoHelper.GetCollection("healthchecks").UpdateOne(r.Context(), bson.M{"_id": current.ID}, update)
+	if err == nil {
+		if err := m.history.record(r.Context(), &restored, rollbackAuthor, number); err != nil {
+			log.Printf("[%s] Failed to record rollback revision: %v", restored.Name, err)
+		}
+	}
+	m.history.mu.Unlock()
+	if err != nil {
+		JSONError(w, "Error saving health check", http.StatusInternalServerError)
+		return
+	}
+
+	if restored.Status == "active" {
+		m.mu.Lock()
+		m.applyHealthCheck(restored, time.Now())
+		m.mu.Unlock()
+	}
+
+	JSONResponse(w, map[string]interface{}{
+		"check":        restored.Name,
+		"rolledBackTo": number,
+		"revision":     restored.Revision,
+		"changed":      changed,
+	}, http.StatusOK)
+}
+
+func (m *HealthCheckManager) revisionRequest(w http.ResponseWriter, r *http.Request) (*HealthCheck, int, bool) {
+	number, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil || number <= 0 {
+		JSONError(w, "revision must be a positive number", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	name := r.PathValue("name")
+	hc, err := m.findHealthCheck(r.Context(), name)
+	if err == mongo.ErrNoDocuments {
+		JSONError(w, fmt.Sprintf("Health check '%s' not found", name), http.StatusNotFound)
+		return nil, 0, false
+	} else if err != nil {
+		JSONError(w, "Error loading health check", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+
+	return hc, number, true
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestHistory(t *testing.T) *ConfigHistory {
+	t.Helper()
+	return NewConfigHistory(NewMongoHelper(newTestDatabase(t)))
+}
+
+func testCheck() HealthCheck {
+	return HealthCheck{
+		ID:         primitive.NewObjectID(),
+		Name:       "api",
+		URL:        "https://api.example.com/health",
+		Method:     "GET",
+		Interval:   60,
+		StatusCode: 200,
+		Headers:    map[string]string{"Authorization": "Bearer live-token", "Accept": "application/json"},
+		Status:     "active",
+		CreatedAt:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Auth:       &AuthConfig{Type: AuthBasic, Username: "svc", Password: "live-password"},
+		Notifications: []NotificationChannel{
+			{Type: ChannelWebhook, URL: "https://hooks.example.com/a", Secret: "secret-a"},
+			{Type: ChannelWebhook, URL: "https://hooks.example.com/b", Secret: "secret-b"},
+		},
+		Heartbeat: &HeartbeatConfig{Token: "live-heartbeat", Period: 60},
+	}
+}
+
+// stored returns hc the way a revision keeps it
+func stored(t *testing.T, h *ConfigHistory, hc HealthCheck) HealthCheck {
+	t.Helper()
+	return h.mongoHelper.redactor.Config(hc)
+}
+
+func TestRevisionConfigIsMasked(t *testing.T) {
+	h := newTestHistory(t)
+	config := stored(t, h, testCheck())
+
+	data, err := bson.MarshalExtJSON(config, false, false)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, value := range []string{"live-token", "live-password", "secret-a", "secret-b", "live-heartbeat"} {
+		if strings.Contains(string(data), value) {
+			t.Errorf("revision keeps %s: %s", value, data)
+		}
+	}
+}
+
+func TestRevisionConfigKeepsNonCredentials(t *testing.T) {
+	h := newTestHistory(t)
+
+	hc := testCheck()
+	hc.URL = "https://api.example.com/health?token=abc"
+	hc.Body = `{"password":"test-fixture","z":"<b>"}`
+	hc.Assertions = []Assertion{{Type: AssertionBody, Operator: OperatorMatches, Value: `Bearer \w+`}}
+	hc.Steps = []HealthCheckStep{{Name: "login", URL: "https://api.example.com/login", Body: "password=fixture", Headers: map[string]string{"X-Api-Key": "step-key"}}}
+
+	config := stored(t, h, hc)
+
+	if config.URL != hc.URL || config.Body != hc.Body || config.Assertions[0].Value != hc.Assertions[0].Value || config.Steps[0].Body != "password=fixture" {
+		t.Errorf("non-credential fields changed: %+v", config)
+	}
+	if config.Steps[0].Headers["X-Api-Key"] != redactedValue {
+		t.Errorf("step headers = %v", config.Steps[0].Headers)
+	}
+
+	// The step and its key are gone from the live document
+	current := testCheck()
+	current.ID = hc.ID
+	if _, _, _, err := h.rollbackUpdate(current, config); err == nil || !strings.Contains(err.Error(), "steps[0].headers.X-Api-Key is redacted") {
+		t.Errorf("err = %v", err)
+	}
+
+	// Without it, a rollback writes everything back as it was
+	config.Steps[0].Headers = nil
+	set, unset, _, err := h.rollbackUpdate(current, config)
+	if err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	restored, err := applyUpdate(current, set, unset)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if restored.URL != hc.URL || restored.Body != hc.Body || restored.Assertions[0].Value != hc.Assertions[0].Value {
+		t.Errorf("restored = %+v", restored)
+	}
+}
+
+func TestRollbackRestoresMaskedValuesFromLiveDocument(t *testing.T) {
+	h := newTestHistory(t)
+
+	old := testCheck()
+	target := stored(t, h, old)
+
+	// Since then the interval changed, the webhooks were reordered and
+	// the password was rotated
+	current := old
+	current.Interval = 30
+	current.URL = "https://api.example.com/v2/health"
+	current.Notifications = []NotificationChannel{old.Notifications[1], old.Notifications[0]}
+	current.Auth = &AuthConfig{Type: AuthBasic, Username: "svc", Password: "rotated-password"}
+
+	set, unset, changed, err := h.rollbackUpdate(current, target)
+	if err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if len(unset) != 0 {
+		t.Errorf("unset = %v", unset)
+	}
+	if strings.Join(changed, ",") != "url,interval,notifications" {
+		t.Errorf("changed = %v", changed)
+	}
+
+	restored, err := applyUpdate(current, set, unset)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if restored.Interval != 60 || restored.URL != old.URL {
+		t.Errorf("restored = %+v", restored)
+	}
+	if restored.Notifications[0].Secret != "secret-a" || restored.Notifications[1].Secret != "secret-b" {
+		t.Errorf("webhook secrets = %q, %q", restored.Notifications[0].Secret, restored.Notifications[1].Secret)
+	}
+	if restored.Auth.Password != "rotated-password" || restored.Headers["Authorization"] != "Bearer live-token" {
+		t.Errorf("credentials were not taken from the live document: %+v %v", restored.Auth, restored.Headers)
+	}
+	if restored.Heartbeat.Token != "live-heartbeat" {
+		t.Errorf("heartbeat token = %q", restored.Heartbeat.Token)
+	}
+	if restored.ID != current.ID || restored.Status != "active" || !restored.CreatedAt.Equal(current.CreatedAt) {
+		t.Errorf("kept fields changed: %+v", restored)
+	}
+}
+
+func TestRollbackUnsetsFieldsTheRevisionDidNotHave(t *testing.T) {
+	h := newTestHistory(t)
+
+	old := testCheck()
+	old.Auth = nil
+	target := stored(t, h, old)
+
+	current := testCheck()
+	current.ID = old.ID
+	current.Retry = &RetryPolicy{Count: 2}
+
+	set, unset, changed, err := h.rollbackUpdate(current, target)
+	if err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if _, ok := unset["auth"]; !ok {
+		t.Errorf("auth not unset: %v", unset)
+	}
+	if _, ok := unset["retry"]; !ok {
+		t.Errorf("retry not unset: %v", unset)
+	}
+	if len(set) != 0 {
+		t.Errorf("set = %v", set)
+	}
+	if len(changed) != 2 {
+		t.Errorf("changed = %v", changed)
+	}
+}
+
+func TestRollbackRefusesUnrecoverableMaskedValues(t *testing.T) {
+	h := newTestHistory(t)
+
+	removedChannel := testCheck()
+	removedChannel.Notifications = append(removedChannel.Notifications, NotificationChannel{Type: ChannelOpsgenie, APIKey: "removed-key"})
+
+	tests := []struct {
+		name   string
+		target HealthCheck
+		err    string
+	}{
+		// The Opsgenie channel and its key are gone from the live document
+		{"removed credential", stored(t, h, removedChannel), "notifications[2].apiKey is redacted"},
+		// Revisions written before only credentials were masked
+		{"masked body", func() HealthCheck {
+			hc := stored(t, h, testCheck())
+			hc.Body = `{"password":"[REDACTED]"}`
+			return hc
+		}(), "body is redacted"},
+	}
+
+	for _, test := range tests {
+		current := testCheck()
+		_, _, _, err := h.rollbackUpdate(current, test.target)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: err = %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestRecordSkipsUnchangedConfig(t *testing.T) {
+	h := newTestHistory(t)
+
+	hc := testCheck()
+	h.latest[hc.ID.Hex()] = &HealthCheckRevision{Revision: 4, Config: stored(t, h, hc)}
+
+	if err := h.Record(context.Background(), &hc, "", 0); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if hc.Revision != 4 {
+		t.Errorf("revision = %d, want 4", hc.Revision)
+	}
+
+	// A rotated credential is a change even though the stored form is equal
+	rotated := hc
+	rotated.Auth = &AuthConfig{Type: AuthBasic, Username: "svc", Password: "rotated"}
+	if err := h.Record(context.Background(), &rotated, "", 0); err == nil {
+		t.Fatal("expected the revision insert to be attempted")
+	}
+}
+
+func TestRevisionResponseIsMasked(t *testing.T) {
+	m := &HealthCheckManager{mongoHelper: NewMongoHelper(newTestDatabase(t))}
+
+	// Written before credentials were masked
+	revision := bson.M{
+		"revision": 2,
+		"author":   "ann",
+		"after":    bson.M{"auth": bson.M{"type": AuthOAuth2, "clientId": "client", "clientSecret": "plain"}},
+		"config":   bson.M{"notifications": bson.A{bson.M{"type": ChannelOpsgenie, "apiKey": "plain"}}},
+	}
+
+	response, err := m.redactedResponse(revision)
+	if err != nil {
+		t.Fatalf("redact: %v", err)
+	}
+
+	auth := response["after"].(bson.M)["auth"].(bson.M)
+	if auth["clientSecret"] != redactedValue || auth["clientId"] != "client" {
+		t.Errorf("auth = %v", auth)
+	}
+	channel := response["config"].(bson.M)["notifications"].(bson.A)[0].(bson.M)
+	if channel["apiKey"] != redactedValue {
+		t.Errorf("channel = %v", channel)
+	}
+	if response["author"] != "ann" {
+		t.Errorf("response = %v", response)
+	}
+}
+
+func TestDocumentAuthor(t *testing.T) {
+	edited := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	latest := &HealthCheckRevision{Revision: 3, Config: HealthCheck{UpdatedBy: "ann", UpdatedAt: edited}}
+
+	tests := []struct {
+		name   string
+		latest *HealthCheckRevision
+		hc     HealthCheck
+		want   string
+	}{
+		{"first revision", nil, HealthCheck{UpdatedBy: "ann", UpdatedAt: edited}, "ann"},
+		{"no author", nil, HealthCheck{}, unknownAuthor},
+		{"new edit", latest, HealthCheck{UpdatedBy: "bob", UpdatedAt: edited.Add(time.Minute)}, "bob"},
+		{"edit that kept the old author", latest, HealthCheck{UpdatedBy: "ann", UpdatedAt: edited}, unknownAuthor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := documentAuthor(tt.latest, tt.hc); got != tt.want {
+				t.Errorf("author = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,110 +1,207 @@
-package main
-
-import (
-	"encoding/json"
-	"log"
-	"net/http"
-	"time"
-)
-
-type HTTPClientConfig struct {
-	Timeout             time.Duration
-	MaxIdleConns        int
-	MaxIdleConnsPerHost int
-	MaxConnsPerHost     int
-	IdleConnTimeout     time.Duration
-}
-
-func DefaultHTTPClientConfig() HTTPClientConfig {
-	return HTTPClientConfig{
-		Timeout:             30 * time.Second,
-		MaxIdleConns:        1000,
-		MaxIdleConnsPerHost: 100,
-		MaxConnsPerHost:     0, // unlimited
-		IdleConnTimeout:     90 * time.Second,
-	}
-}
-
-func NewHTTPClient(config HTTPClientConfig) *http.Client {
-	transport := &http.Transport{
-		MaxIdleConns:        config.MaxIdleConns,
-		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
-		MaxConnsPerHost:     config.MaxConnsPerHost,
-		IdleConnTimeout:     config.IdleConnTimeout,
-	}
-
-	return &http.Client{
-		Timeout:   config.Timeout,
-		Transport: transport,
-	}
-}
-
-func NewDefaultHTTPClient() *http.Client {
-	return NewHTTPClient(DefaultHTTPClientConfig())
-}
-
-func NewHTTPClientWithTimeout(timeout time.Duration) *http.Client {
-	config := DefaultHTTPClientConfig()
-	config.Timeout = timeout
-	return NewHTTPClient(config)
-}
-
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		log.Printf("→ %s %s", r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
-		log.Printf("← %s %s [%v]", r.Method, r.URL.Path, time.Since(start))
-	})
-}
-
-func JSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
-	}
-}
-
-func JSONError(w http.ResponseWriter, message string, statusCode int) {
-	JSONResponse(w, map[string]string{"error": message}, statusCode)
-}
-
-type HealthResponse struct {
-	Status string `json:"status"`
-	Time   string `json:"time"`
-}
-
-func HandleHealth(w http.ResponseWriter, r *http.Request) {
-	JSONResponse(w, HealthResponse{
-		Status: "ok",
-		Time:   time.Now().Format(time.RFC3339),
-	}, http.StatusOK)
-}
-
-func CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
-func RecoveryMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
-				JSONError(w, "Internal server error", http.StatusInternalServerError)
-			}
-		}()
-		next.ServeHTTP(w, r)
-	})
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const defaultMaxRedirects = 10
+
+type HTTPClientConfig struct {
+	Timeout             time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+
+	DisableRedirects   bool
+	MaxRedirects       int    // 0 means 10, like net/http
+	ProxyURL           string // http://, https:// or socks5://
+	InsecureSkipVerify bool
+	CACertPEM          string // trusted instead of the system roots
+	ClientCertPEM      string // with ClientKeyPEM, presented for mTLS
+	ClientKeyPEM       string
+}
+
+func DefaultHTTPClientConfig() HTTPClientConfig {
+	return HTTPClientConfig{
+		Timeout:             30 * time.Second,
+		MaxIdleConns:        1000,
+		MaxIdleConnsPerHost: 100,
+		MaxConnsPerHost:     0, // unlimited
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+func NewHTTPClient(config HTTPClientConfig) *http.Client {
+	transport, err := NewHTTPTransport(config)
+	if err != nil {
+		log.Printf("Invalid HTTP client config, using defaults: %v", err)
+		transport, _ = NewHTTPTransport(DefaultHTTPClientConfig())
+	}
+
+	return &http.Client{
+		Timeout:       config.Timeout,
+		Transport:     transport,
+		CheckRedirect: config.checkRedirect,
+	}
+}
+
+func NewHTTPTransport(config HTTPClientConfig) (*http.Transport, error) {
+	transport := &http.Transport{
+		MaxIdleConns:        config.MaxIdleConns,
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     config.MaxConnsPerHost,
+		IdleConnTimeout:     config.IdleConnTimeout,
+	}
+
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme '%s'", proxyURL.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+// TLSConfig returns nil when the config has no TLS settings of its own
+func (c HTTPClientConfig) TLSConfig() (*tls.Config, error) {
+	if !c.InsecureSkipVerify && c.CACertPEM == "" && c.ClientCertPEM == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+
+	roots, err := c.RootCAs()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.RootCAs = roots
+
+	if c.ClientCertPEM != "" {
+		cert, err := tls.X509KeyPair([]byte(c.ClientCertPEM), []byte(c.ClientKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// RootCAs returns nil (the system pool) unless a CA bundle is configured
+func (c HTTPClientConfig) RootCAs() (*x509.CertPool, error) {
+	if c.CACertPEM == "" {
+		return nil, nil
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(c.CACertPEM)) {
+		return nil, fmt.Errorf("invalid CA bundle: no PEM certificates found")
+	}
+	return roots, nil
+}
+
+func (c HTTPClientConfig) checkRedirect(req *http.Request, via []*http.Request) error {
+	if c.DisableRedirects {
+		return http.ErrUseLastResponse
+	}
+
+	maxRedirects := c.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return nil
+}
+
+func NewDefaultHTTPClient() *http.Client {
+	return NewHTTPClient(DefaultHTTPClientConfig())
+}
+
+func NewHTTPClientWithTimeout(timeout time.Duration) *http.Client {
+	config := DefaultHTTPClientConfig()
+	config.Timeout = timeout
+	return NewHTTPClient(config)
+}
+
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		log.Printf("→ %s %s", r.Method, r.URL.Path)
+		next.ServeHTTP(w, r)
+		log.Printf("← %s %s [%v]", r.Method, r.URL.Path, time.Since(start))
+	})
+}
+
+func JSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+func JSONError(w http.ResponseWriter, message string, statusCode int) {
+	JSONResponse(w, map[string]string{"error": message}, statusCode)
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+	Time   string `json:"time"`
+}
+
+func HandleHealth(w http.ResponseWriter, r *http.Request) {
+	JSONResponse(w, HealthResponse{
+		Status: "ok",
+		Time:   time.Now().Format(time.RFC3339),
+	}, http.StatusOK)
+}
+
+func CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("Panic recovered: %v", err)
+				JSONError(w, "Internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
 }
\ No newline at end of file
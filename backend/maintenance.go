@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maintenanceCollection = "maintenance_windows"
+
+// MaintenanceWindow covers the checks listed by name or carrying one of the
+// tags. One-off windows run from start to end; recurring windows open at
+// every schedule occurrence for duration minutes, optionally bounded by
+// start and end.
+type MaintenanceWindow struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Checks    []string           `bson:"checks,omitempty" json:"checks,omitempty"`
+	Tags      []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	Start     time.Time          `bson:"start,omitempty" json:"start,omitzero"`
+	End       time.Time          `bson:"end,omitempty" json:"end,omitzero"`
+	Schedule  string             `bson:"schedule,omitempty" json:"schedule,omitempty"`
+	Duration  int                `bson:"duration,omitempty" json:"duration,omitempty"` // minutes
+	Timezone  string             `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	Status    string             `bson:"status" json:"status"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+
+	cron *CronSchedule
+}
+
+type maintenanceWindowView struct {
+	MaintenanceWindow
+	Active bool `json:"active"`
+}
+
+func (w *MaintenanceWindow) prepare() error {
+	if w.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(w.Checks) == 0 && len(w.Tags) == 0 {
+		return fmt.Errorf("at least one check or tag is required")
+	}
+
+	if w.Schedule == "" {
+		if w.Start.IsZero() || !w.End.After(w.Start) {
+			return fmt.Errorf("one-off windows need a start before their end")
+		}
+		return nil
+	}
+
+	if w.Duration <= 0 {
+		return fmt.Errorf("recurring windows need a duration in minutes")
+	}
+
+	cron, err := ParseCronSchedule(w.Schedule, w.Timezone)
+	if err != nil {
+		return err
+	}
+	w.cron = cron
+
+	return nil
+}
+
+func (w *MaintenanceWindow) ActiveAt(t time.Time) bool {
+	if !w.Start.IsZero() && t.Before(w.Start) {
+		return false
+	}
+	if !w.End.IsZero() && !t.Before(w.End) {
+		return false
+	}
+
+	if w.cron == nil {
+		return w.Schedule == ""
+	}
+
+	// The latest occurrence that could still be open started after t-duration
+	duration := time.Duration(w.Duration) * time.Minute
+	occurrence := w.cron.Next(t.Add(-duration))
+	return !occurrence.IsZero() && !occurrence.After(t)
+}
+
+func (w *MaintenanceWindow) Covers(hc HealthCheck) bool {
+	for _, name := range w.Checks {
+		if name == hc.Name {
+			return true
+		}
+	}
+	for _, tag := range w.Tags {
+		for _, checkTag := range hc.Tags {
+			if tag == checkTag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *HealthCheckManager) loadMaintenanceWindows(ctx context.Context) {
+	var windows []MaintenanceWindow
+	err := m.mongoHelper.FindActiveDocuments(ctx, maintenanceCollection, &windows)
+	if err != nil {
+		log.Println("Failed to load maintenance windows:", err)
+		return
+	}
+
+	valid := windows[:0]
+	for _, window := range windows {
+		if err := window.prepare(); err != nil {
+			log.Printf("Ignoring maintenance window %s: %v", window.Name, err)
+			continue
+		}
+		valid = append(valid, window)
+	}
+
+	m.mu.Lock()
+	m.maintenance = valid
+	m.mu.Unlock()
+}
+
+// activeMaintenance returns the window a check is currently in, if any
+func (m *HealthCheckManager) activeMaintenance(hc HealthCheck, now time.Time) *MaintenanceWindow {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := range m.maintenance {
+		window := &m.maintenance[i]
+		if window.Covers(hc) && window.ActiveAt(now) {
+			return window
+		}
+	}
+	return nil
+}
+
+// HandleMaintenanceWindows lists (GET) and creates (POST) maintenance windows
+func (m *HealthCheckManager) HandleMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m.mu.RLock()
+		now := time.Now()
+		views := make([]maintenanceWindowView, 0, len(m.maintenance))
+		for _, window := range m.maintenance {
+			views = append(views, maintenanceWindowView{MaintenanceWindow: window, Active: window.ActiveAt(now)})
+		}
+		m.mu.RUnlock()
+
+		JSONResponse(w, views, http.StatusOK)
+
+	case http.MethodPost:
+		var window MaintenanceWindow
+		if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+			JSONError(w, fmt.Sprintf("Error decoding JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := window.prepare(); err != nil {
+			JSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		window.ID = primitive.NewObjectID()
+		window.Status = "active"
+		window.CreatedAt = time.Now()
+
+		if err := m.mongoHelper.InsertLog(r.Context(), maintenanceCollection, window); err != nil {
+			JSONError(w, "Error saving maintenance window", http.StatusInternalServerError)
+			return
+		}
+
+		m.loadMaintenanceWindows(r.Context())
+		JSONResponse(w, window, http.StatusCreated)
+
+	default:
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleMaintenanceWindow ends a window early (DELETE)
+func (m *HealthCheckManager) HandleMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		JSONError(w, "Invalid maintenance window id", http.StatusBadRequest)
+		return
+	}
+
+	result, err := m.mongoHelper.GetCollection(maintenanceCollection).UpdateOne(r.Context(),
+		bson.M{"_id": id}, bson.M{"$set": bson.M{"status": "inactive"}})
+	if err != nil {
+		JSONError(w, "Error updating maintenance window", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		JSONError(w, "Maintenance window not found", http.StatusNotFound)
+		return
+	}
+
+	m.loadMaintenanceWindows(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowPrepare(t *testing.T) {
+	start := time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		window MaintenanceWindow
+		err    string
+	}{
+		{"one-off", MaintenanceWindow{Name: "deploy", Checks: []string{"api"}, Start: start, End: start.Add(time.Hour)}, ""},
+		{"recurring", MaintenanceWindow{Name: "backup", Tags: []string{"db"}, Schedule: "0 2 * * sun", Duration: 30}, ""},
+		{"no name", MaintenanceWindow{Checks: []string{"api"}, Start: start, End: start.Add(time.Hour)}, "name is required"},
+		{"no target", MaintenanceWindow{Name: "deploy", Start: start, End: start.Add(time.Hour)}, "at least one check or tag is required"},
+		{"end before start", MaintenanceWindow{Name: "deploy", Checks: []string{"api"}, Start: start, End: start}, "one-off windows need a start before their end"},
+		{"no duration", MaintenanceWindow{Name: "backup", Tags: []string{"db"}, Schedule: "0 2 * * sun"}, "recurring windows need a duration in minutes"},
+		{"bad schedule", MaintenanceWindow{Name: "backup", Tags: []string{"db"}, Schedule: "0 2 * *", Duration: 30}, "expected 5 fields"},
+	}
+
+	for _, test := range tests {
+		err := test.window.prepare()
+		if (test.err == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: err = %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestMaintenanceWindowActiveAt(t *testing.T) {
+	start := time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC)
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	oneOff := MaintenanceWindow{Name: "deploy", Checks: []string{"api"}, Start: start, End: start.Add(time.Hour)}
+	nightly := MaintenanceWindow{Name: "backup", Tags: []string{"db"}, Schedule: "0 2 * * *", Duration: 30, Timezone: "Europe/Berlin"}
+	bounded := MaintenanceWindow{Name: "migration", Tags: []string{"db"}, Schedule: "0 2 * * *", Duration: 30, Start: start, End: start.Add(48 * time.Hour)}
+	for _, window := range []*MaintenanceWindow{&oneOff, &nightly, &bounded} {
+		if err := window.prepare(); err != nil {
+			t.Fatalf("prepare %s: %v", window.Name, err)
+		}
+	}
+
+	tests := []struct {
+		window *MaintenanceWindow
+		at     time.Time
+		active bool
+	}{
+		{&oneOff, start.Add(-time.Second), false},
+		{&oneOff, start, true},
+		{&oneOff, start.Add(59 * time.Minute), true},
+		{&oneOff, start.Add(time.Hour), false},
+		{&nightly, time.Date(2024, 3, 2, 1, 59, 0, 0, berlin), false},
+		{&nightly, time.Date(2024, 3, 2, 2, 0, 0, 0, berlin), true},
+		{&nightly, time.Date(2024, 3, 2, 2, 29, 59, 0, berlin), true},
+		{&nightly, time.Date(2024, 3, 2, 2, 30, 0, 0, berlin), false},
+		{&nightly, time.Date(2024, 3, 2, 1, 15, 0, 0, time.UTC), true},
+		{&bounded, time.Date(2024, 3, 1, 2, 10, 0, 0, time.UTC), false},
+		{&bounded, time.Date(2024, 3, 2, 2, 10, 0, 0, time.UTC), true},
+		{&bounded, time.Date(2024, 3, 4, 2, 10, 0, 0, time.UTC), false},
+	}
+
+	for _, test := range tests {
+		if got := test.window.ActiveAt(test.at); got != test.active {
+			t.Errorf("%s at %v: active = %v", test.window.Name, test.at, got)
+		}
+	}
+}
+
+func TestMaintenanceWindowCovers(t *testing.T) {
+	window := MaintenanceWindow{Checks: []string{"api"}, Tags: []string{"db", "eu"}}
+
+	tests := []struct {
+		hc     HealthCheck
+		covers bool
+	}{
+		{HealthCheck{Name: "api"}, true},
+		{HealthCheck{Name: "postgres", Tags: []string{"prod", "db"}}, true},
+		{HealthCheck{Name: "web", Tags: []string{"prod"}}, false},
+		{HealthCheck{Name: "api-v2"}, false},
+	}
+
+	for _, test := range tests {
+		if got := window.Covers(test.hc); got != test.covers {
+			t.Errorf("covers %s = %v", test.hc.Name, got)
+		}
+	}
+}
+
+func TestActiveMaintenance(t *testing.T) {
+	start := time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC)
+	m := &HealthCheckManager{maintenance: []MaintenanceWindow{
+		{Name: "web deploy", Checks: []string{"web"}, Start: start, End: start.Add(time.Hour)},
+		{Name: "api deploy", Checks: []string{"api"}, Start: start.Add(2 * time.Hour), End: start.Add(3 * time.Hour)},
+		{Name: "api hotfix", Checks: []string{"api"}, Start: start, End: start.Add(time.Hour)},
+	}}
+
+	if window := m.activeMaintenance(HealthCheck{Name: "api"}, start.Add(time.Minute)); window == nil || window.Name != "api hotfix" {
+		t.Errorf("window = %+v", window)
+	}
+	if window := m.activeMaintenance(HealthCheck{Name: "api"}, start.Add(90*time.Minute)); window != nil {
+		t.Errorf("window = %+v", window)
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	EventStateChange = "state_change"
+	EventTest        = "test"
+	EventBurnRate    = "burn_rate"
+
+	notificationDeliveriesCollection = "notification_deliveries"
+
+	defaultNotifyAttempts = 5
+	defaultNotifyBackoff  = 2 * time.Second
+)
+
+type NotificationChannel struct {
+	Type     string            `bson:"type" json:"type"`
+	URL      string            `bson:"url,omitempty" json:"url,omitempty"`       // webhook/Slack URL, or API base override for PagerDuty and Opsgenie
+	Secret   string            `bson:"secret,omitempty" json:"secret,omitempty"` // HMAC key for webhook signatures
+	Headers  map[string]string `bson:"headers,omitempty" json:"headers,omitempty"`
+	OnStates []string          `bson:"onStates,omitempty" json:"onStates,omitempty"` // empty means every state
+
+	RoutingKey string `bson:"routingKey,omitempty" json:"routingKey,omitempty"` // PagerDuty integration key
+	APIKey     string `bson:"apiKey,omitempty" json:"apiKey,omitempty"`         // Opsgenie GenieKey
+
+	// SMTP settings fall back to the SMTP_* environment variables
+	SMTPHost string   `bson:"smtpHost,omitempty" json:"smtpHost,omitempty"`
+	SMTPPort int      `bson:"smtpPort,omitempty" json:"smtpPort,omitempty"`
+	Username string   `bson:"username,omitempty" json:"username,omitempty"`
+	Password string   `bson:"password,omitempty" json:"password,omitempty"`
+	From     string   `bson:"from,omitempty" json:"from,omitempty"`
+	To       []string `bson:"to,omitempty" json:"to,omitempty"`
+}
+
+type Notification struct {
+	Event     string    `json:"event"`
+	CheckID   string    `json:"checkId,omitempty"`
+	CheckName string    `json:"checkName"`
+	URL       string    `json:"url,omitempty"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	Rule      string    `json:"rule,omitempty"`     // burn rate rule
+	Severity  string    `json:"severity,omitempty"` // page or ticket for burn rate alerts
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type NotificationDelivery struct {
+	CheckName  string    `bson:"checkName" json:"checkName"`
+	Channel    string    `bson:"channel" json:"channel"`
+	Target     string    `bson:"target" json:"target"`
+	Event      string    `bson:"event" json:"event"`
+	State      string    `bson:"state,omitempty" json:"state,omitempty"`
+	Attempts   int       `bson:"attempts" json:"attempts"`
+	Success    bool      `bson:"success" json:"success"`
+	StatusCode int       `bson:"statusCode,omitempty" json:"statusCode,omitempty"`
+	Error      *string   `bson:"error,omitempty" json:"error,omitempty"`
+	Duration   int64     `bson:"duration" json:"duration"` // ms
+	Timestamp  time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+type Notifier interface {
+	Type() string
+	Send(ctx context.Context, channel NotificationChannel, notification Notification) error
+}
+
+// HTTPStatusError is returned by notifiers when the receiver answers with a
+// non-2xx status; 429 and 5xx are retried, anything else is final.
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *HTTPStatusError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+func newNotificationFromTransition(hc HealthCheck, transition *StateTransition) Notification {
+	return Notification{
+		Event:     EventStateChange,
+		CheckID:   hc.ID.Hex(),
+		CheckName: hc.Name,
+		URL:       hc.URL,
+		From:      transition.From,
+		To:        transition.To,
+		Reason:    transition.Reason,
+		Detail:    transition.Detail,
+		Timestamp: transition.Timestamp,
+	}
+}
+
+func (c NotificationChannel) accepts(notification Notification) bool {
+	if notification.Event != EventStateChange || len(c.OnStates) == 0 {
+		return true
+	}
+	for _, state := range c.OnStates {
+		if state == notification.To {
+			return true
+		}
+	}
+	return false
+}
+
+func (n Notification) IsProblem() bool {
+	return n.To != StateUp && n.To != AlertResolved
+}
+
+// IsCritical is true for outages and paging burn rate alerts
+func (n Notification) IsCritical() bool {
+	return n.To == StateDown || (n.To == AlertFiring && n.Severity == SeverityPage)
+}
+
+func (n Notification) Summary() string {
+	summary := fmt.Sprintf("[%s] %s", n.To, n.CheckName)
+	if n.Reason != "" {
+		summary += ": " + n.Reason
+	}
+	return summary
+}
+
+func (n Notification) Description() string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Check: %s", n.CheckName))
+	if n.URL != "" {
+		lines = append(lines, fmt.Sprintf("URL: %s", n.URL))
+	}
+	if n.Rule != "" {
+		lines = append(lines, fmt.Sprintf("Rule: %s (%s)", n.Rule, n.Severity))
+	}
+	lines = append(lines, fmt.Sprintf("State: %s -> %s", n.From, n.To))
+	if n.Reason != "" {
+		lines = append(lines, fmt.Sprintf("Reason: %s", n.Reason))
+	}
+	if n.Detail != "" {
+		lines = append(lines, fmt.Sprintf("Detail: %s", n.Detail))
+	}
+	lines = append(lines, fmt.Sprintf("Time: %s", n.Timestamp.Format(time.RFC3339)))
+	return strings.Join(lines, "\n")
+}
+
+// DedupKey groups the trigger and resolve events of the same problem
+func (n Notification) DedupKey() string {
+	if n.Rule != "" {
+		return "hst-" + n.CheckName + "-" + n.Rule
+	}
+	return "hst-" + n.CheckName
+}
+
+// target identifies the receiver in delivery logs without leaking
+// credentials that are often embedded in webhook paths
+func (c NotificationChannel) target() string {
+	if c.Type == ChannelEmail {
+		return strings.Join(c.To, ",")
+	}
+	parsed, err := url.Parse(c.URL)
+	if err != nil || parsed.Host == "" {
+		return c.Type
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+type NotificationDispatcher struct {
+	mongoHelper *MongoHelper
+	client      *http.Client
+	notifiers   map[string]Notifier
+	mu          sync.RWMutex
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func NewNotificationDispatcher(db *mongo.Database) *NotificationDispatcher {
+	d := &NotificationDispatcher{
+		mongoHelper: NewMongoHelper(db),
+		client:      NewHTTPClientWithTimeout(10 * time.Second),
+		notifiers:   make(map[string]Notifier),
+		maxAttempts: defaultNotifyAttempts,
+		backoff:     defaultNotifyBackoff,
+	}
+
+	d.Register(NewWebhookNotifier(d.client))
+	d.Register(NewSlackNotifier(d.client))
+	d.Register(NewPagerDutyNotifier(d.client))
+	d.Register(NewOpsgenieNotifier(d.client))
+	d.Register(NewEmailNotifier())
+
+	return d
+}
+
+func (d *NotificationDispatcher) Register(notifier Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notifiers[notifier.Type()] = notifier
+}
+
+func (d *NotificationDispatcher) notifier(channelType string) (Notifier, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	notifier, ok := d.notifiers[channelType]
+	return notifier, ok
+}
+
+func (d *NotificationDispatcher) Notify(ctx context.Context, channels []NotificationChannel, notification Notification) {
+	for _, channel := range channels {
+		if !channel.accepts(notification) {
+			continue
+		}
+		go d.deliver(ctx, channel, notification, d.maxAttempts)
+	}
+}
+
+func (d *NotificationDispatcher) deliver(ctx context.Context, channel NotificationChannel, notification Notification, maxAttempts int) NotificationDelivery {
+	start := time.Now()
+
+	delivery := NotificationDelivery{
+		CheckName: notification.CheckName,
+		Channel:   channel.Type,
+		Target:    channel.target(),
+		Event:     notification.Event,
+		State:     notification.To,
+	}
+
+	var err error
+	notifier, ok := d.notifier(channel.Type)
+	if !ok {
+		err = fmt.Errorf("unknown notification channel type '%s'", channel.Type)
+	} else {
+		backoff := d.backoff
+		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			delivery.Attempts = attempt
+			err = notifier.Send(ctx, channel, notification)
+			if err == nil || !isRetryableDeliveryError(err) || attempt == maxAttempts {
+				break
+			}
+
+			log.Printf("Notification to %s for %s failed (attempt %d/%d): %v", delivery.Target, notification.CheckName, attempt, maxAttempts, err)
+
+			if !sleepContext(ctx, backoff) {
+				err = ctx.Err()
+				break
+			}
+			backoff *= 2
+		}
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		delivery.StatusCode = statusErr.StatusCode
+	}
+
+	if err != nil {
+		errMsg := err.Error()
+		delivery.Error = &errMsg
+		log.Printf("Notification to %s for %s failed: %v", delivery.Target, notification.CheckName, err)
+	} else {
+		delivery.Success = true
+	}
+
+	delivery.Duration = time.Since(start).Milliseconds()
+	delivery.Timestamp = time.Now()
+
+	if err := d.mongoHelper.InsertLog(ctx, notificationDeliveriesCollection, delivery); err != nil {
+		log.Printf("Failed to save notification delivery for %s: %v", notification.CheckName, err)
+	}
+
+	return delivery
+}
+
+func isRetryableDeliveryError(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Retryable()
+	}
+	return true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// postJSON is shared by the HTTP based notifiers
+func postJSON(ctx context.Context, client *http.Client, targetURL string, payload []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	return nil
+}
+
+type TestNotificationRequest struct {
+	CheckName string               `json:"checkName,omitempty"`
+	Channel   *NotificationChannel `json:"channel,omitempty"`
+}
+
+// HandleTestSend delivers a synthetic notification either to a single
+// channel from the request body or to every channel of an existing check.
+func (d *NotificationDispatcher) HandleTestSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req TestNotificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONError(w, fmt.Sprintf("Error decoding JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	notification := Notification{
+		Event:     EventTest,
+		CheckName: req.CheckName,
+		From:      StateUp,
+		To:        StateDown,
+		Reason:    "test notification",
+		Timestamp: time.Now(),
+	}
+
+	var channels []NotificationChannel
+	switch {
+	case req.Channel != nil:
+		channels = append(channels, *req.Channel)
+		if notification.CheckName == "" {
+			notification.CheckName = "test"
+		}
+	case req.CheckName != "":
+		var hc HealthCheck
+		err := d.mongoHelper.GetCollection("healthchecks").FindOne(r.Context(), bson.M{"name": req.CheckName}).Decode(&hc)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				JSONError(w, fmt.Sprintf("Health check '%s' not found", req.CheckName), http.StatusNotFound)
+				return
+			}
+			JSONError(w, fmt.Sprintf("Error loading health check: %v", err), http.StatusInternalServerError)
+			return
+		}
+		channels = hc.Notifications
+		notification.CheckID = hc.ID.Hex()
+		notification.URL = hc.URL
+	default:
+		JSONError(w, "channel or checkName is required", http.StatusBadRequest)
+		return
+	}
+
+	deliveries := make([]NotificationDelivery, 0, len(channels))
+	for _, channel := range channels {
+		deliveries = append(deliveries, d.deliver(r.Context(), channel, notification, 1))
+	}
+
+	JSONResponse(w, map[string]interface{}{
+		"deliveries": deliveries,
+	}, http.StatusOK)
+}
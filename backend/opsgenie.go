@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	ChannelOpsgenie = "opsgenie"
+
+	opsgenieAPIURL = "https://api.opsgenie.com"
+)
+
+type OpsgenieNotifier struct {
+	client *http.Client
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority"`
+	Source      string            `json:"source"`
+	Entity      string            `json:"entity,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note,omitempty"`
+}
+
+func NewOpsgenieNotifier(client *http.Client) *OpsgenieNotifier {
+	return &OpsgenieNotifier{client: client}
+}
+
+func (n *OpsgenieNotifier) Type() string {
+	return ChannelOpsgenie
+}
+
+func (n *OpsgenieNotifier) Send(ctx context.Context, channel NotificationChannel, notification Notification) error {
+	if channel.APIKey == "" {
+		return fmt.Errorf("opsgenie apiKey is required")
+	}
+
+	baseURL := strings.TrimSuffix(channel.URL, "/")
+	if baseURL == "" {
+		baseURL = opsgenieAPIURL
+	}
+
+	headers := make(map[string]string, len(channel.Headers)+1)
+	for key, value := range channel.Headers {
+		headers[key] = value
+	}
+	headers["Authorization"] = "GenieKey " + channel.APIKey
+
+	alias := notification.DedupKey()
+
+	var (
+		targetURL string
+		body      interface{}
+	)
+
+	if notification.IsProblem() {
+		targetURL = baseURL + "/v2/alerts"
+		body = opsgenieAlert{
+			Message:     truncate(notification.Summary(), 130),
+			Alias:       alias,
+			Description: notification.Description(),
+			Priority:    opsgeniePriority(notification),
+			Source:      "hst",
+			Entity:      notification.CheckName,
+			Tags:        []string{"hst", notification.CheckName},
+			Details: map[string]string{
+				"event":  notification.Event,
+				"from":   notification.From,
+				"to":     notification.To,
+				"url":    notification.URL,
+				"reason": notification.Reason,
+			},
+		}
+	} else {
+		targetURL = fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", baseURL, url.PathEscape(alias))
+		body = opsgenieClose{
+			Source: "hst",
+			Note:   notification.Reason,
+		}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error encoding opsgenie payload: %w", err)
+	}
+
+	return postJSON(ctx, n.client, targetURL, payload, headers)
+}
+
+func opsgeniePriority(notification Notification) string {
+	if notification.IsCritical() {
+		return "P1"
+	}
+	return "P3"
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpsgenieCreateAndClose(t *testing.T) {
+	capture := &captureJSON{}
+	server := capture.server(t)
+
+	notifier := NewOpsgenieNotifier(server.Client())
+	channel := NotificationChannel{Type: ChannelOpsgenie, URL: server.URL + "/", APIKey: "g3n1e"}
+
+	down := testNotification(StateDown)
+	if err := notifier.Send(context.Background(), channel, down); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := notifier.Send(context.Background(), channel, testNotification(StateUp)); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if len(capture.bodies) != 2 {
+		t.Fatalf("requests = %v", capture.paths)
+	}
+	for i, headers := range capture.headers {
+		if headers.Get("Authorization") != "GenieKey g3n1e" {
+			t.Errorf("request %d authorization = %q", i, headers.Get("Authorization"))
+		}
+	}
+
+	if capture.paths[0] != "/v2/alerts" {
+		t.Errorf("create path = %q", capture.paths[0])
+	}
+	alert := capture.bodies[0]
+	if alert["alias"] != "hst-api" || alert["priority"] != "P1" || alert["source"] != "hst" || alert["entity"] != "api" {
+		t.Errorf("alert = %v", alert)
+	}
+	if alert["message"] != down.Summary() || !strings.Contains(alert["description"].(string), "State: UP -> DOWN") {
+		t.Errorf("alert = %v", alert)
+	}
+	details := alert["details"].(map[string]interface{})
+	if details["url"] != down.URL || details["to"] != StateDown {
+		t.Errorf("details = %v", details)
+	}
+
+	if capture.paths[1] != "/v2/alerts/hst-api/close?identifierType=alias" {
+		t.Errorf("close path = %q", capture.paths[1])
+	}
+	if capture.bodies[1]["source"] != "hst" {
+		t.Errorf("close = %v", capture.bodies[1])
+	}
+}
+
+func TestOpsgenieAliasIsEscaped(t *testing.T) {
+	capture := &captureJSON{}
+	server := capture.server(t)
+
+	notification := testNotification(StateUp)
+	notification.CheckName = "api/eu west"
+	notification.Rule = "fast-burn"
+
+	channel := NotificationChannel{Type: ChannelOpsgenie, URL: server.URL, APIKey: "g3n1e"}
+	if err := NewOpsgenieNotifier(server.Client()).Send(context.Background(), channel, notification); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if capture.paths[0] != "/v2/alerts/hst-api%2Feu%20west-fast-burn/close?identifierType=alias" {
+		t.Errorf("close path = %q", capture.paths[0])
+	}
+}
+
+func TestOpsgeniePriorityAndLongMessages(t *testing.T) {
+	capture := &captureJSON{}
+	server := capture.server(t)
+
+	notification := testNotification(StateDegraded)
+	notification.Reason = strings.Repeat("slow ", 50)
+
+	channel := NotificationChannel{Type: ChannelOpsgenie, URL: server.URL, APIKey: "g3n1e"}
+	if err := NewOpsgenieNotifier(server.Client()).Send(context.Background(), channel, notification); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	alert := capture.bodies[0]
+	if alert["priority"] != "P3" {
+		t.Errorf("priority = %v", alert["priority"])
+	}
+	if len(alert["message"].(string)) != 130 {
+		t.Errorf("message is %d characters, want 130", len(alert["message"].(string)))
+	}
+}
+
+func TestOpsgenieRequiresAPIKey(t *testing.T) {
+	err := NewOpsgenieNotifier(http.DefaultClient).Send(context.Background(), NotificationChannel{Type: ChannelOpsgenie}, testNotification(StateDown))
+	if err == nil {
+		t.Fatal("expected an error without apiKey")
+	}
+}
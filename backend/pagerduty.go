@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	ChannelPagerDuty = "pagerduty"
+
+	pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+)
+
+type PagerDutyNotifier struct {
+	client *http.Client
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp"`
+	Component     string            `json:"component"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+func NewPagerDutyNotifier(client *http.Client) *PagerDutyNotifier {
+	return &PagerDutyNotifier{client: client}
+}
+
+func (n *PagerDutyNotifier) Type() string {
+	return ChannelPagerDuty
+}
+
+func (n *PagerDutyNotifier) Send(ctx context.Context, channel NotificationChannel, notification Notification) error {
+	if channel.RoutingKey == "" {
+		return fmt.Errorf("pagerduty routingKey is required")
+	}
+
+	event := pagerDutyEvent{
+		RoutingKey:  channel.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    notification.DedupKey(),
+	}
+
+	if notification.IsProblem() {
+		source := notification.URL
+		if source == "" {
+			source = notification.CheckName
+		}
+
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:   notification.Summary(),
+			Source:    source,
+			Severity:  pagerDutySeverity(notification),
+			Timestamp: notification.Timestamp.Format(time.RFC3339),
+			Component: notification.CheckName,
+			CustomDetails: map[string]string{
+				"event":  notification.Event,
+				"from":   notification.From,
+				"to":     notification.To,
+				"reason": notification.Reason,
+				"detail": notification.Detail,
+			},
+		}
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding pagerduty event: %w", err)
+	}
+
+	targetURL := channel.URL
+	if targetURL == "" {
+		targetURL = pagerDutyEventsURL
+	}
+
+	return postJSON(ctx, n.client, targetURL, payload, channel.Headers)
+}
+
+func pagerDutySeverity(notification Notification) string {
+	if notification.IsCritical() {
+		return "critical"
+	}
+	return "warning"
+}
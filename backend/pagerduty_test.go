@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestPagerDutyTriggerAndResolve(t *testing.T) {
+	capture := &captureJSON{}
+	server := capture.server(t)
+
+	notifier := NewPagerDutyNotifier(server.Client())
+	channel := NotificationChannel{Type: ChannelPagerDuty, URL: server.URL + "/v2/enqueue", RoutingKey: "R0UT1NG"}
+
+	down := testNotification(StateDown)
+	if err := notifier.Send(context.Background(), channel, down); err != nil {
+		t.Fatalf("trigger: %v", err)
+	}
+	if err := notifier.Send(context.Background(), channel, testNotification(StateUp)); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	if len(capture.bodies) != 2 {
+		t.Fatalf("requests = %v", capture.paths)
+	}
+	trigger, resolve := capture.bodies[0], capture.bodies[1]
+
+	if trigger["routing_key"] != "R0UT1NG" || trigger["event_action"] != "trigger" || trigger["dedup_key"] != "hst-api" {
+		t.Errorf("trigger = %v", trigger)
+	}
+	payload := trigger["payload"].(map[string]interface{})
+	if payload["severity"] != "critical" || payload["source"] != down.URL || payload["component"] != "api" {
+		t.Errorf("payload = %v", payload)
+	}
+	if payload["summary"] != down.Summary() || payload["timestamp"] != "2024-03-01T12:00:00Z" {
+		t.Errorf("payload = %v", payload)
+	}
+	details := payload["custom_details"].(map[string]interface{})
+	if details["from"] != StateUp || details["to"] != StateDown || details["reason"] != down.Reason {
+		t.Errorf("custom details = %v", details)
+	}
+
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != trigger["dedup_key"] {
+		t.Errorf("resolve = %v", resolve)
+	}
+	if _, ok := resolve["payload"]; ok {
+		t.Errorf("resolve carries a payload: %v", resolve)
+	}
+}
+
+func TestPagerDutySeverityAndDedupKey(t *testing.T) {
+	capture := &captureJSON{}
+	server := capture.server(t)
+
+	notifier := NewPagerDutyNotifier(server.Client())
+	channel := NotificationChannel{Type: ChannelPagerDuty, URL: server.URL, RoutingKey: "R0UT1NG"}
+
+	degraded := testNotification(StateDegraded)
+	burn := Notification{
+		Event:     EventBurnRate,
+		CheckName: "api",
+		From:      AlertResolved,
+		To:        AlertFiring,
+		Rule:      "fast-burn",
+		Severity:  SeverityPage,
+	}
+	for _, notification := range []Notification{degraded, burn} {
+		if err := notifier.Send(context.Background(), channel, notification); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	first := capture.bodies[0]["payload"].(map[string]interface{})
+	if first["severity"] != "warning" {
+		t.Errorf("degraded severity = %v", first["severity"])
+	}
+	if capture.bodies[1]["dedup_key"] != "hst-api-fast-burn" {
+		t.Errorf("burn rate dedup key = %v", capture.bodies[1]["dedup_key"])
+	}
+	second := capture.bodies[1]["payload"].(map[string]interface{})
+	if second["severity"] != "critical" || second["source"] != "api" {
+		t.Errorf("burn rate payload = %v", second)
+	}
+}
+
+func TestPagerDutyRequiresRoutingKey(t *testing.T) {
+	err := NewPagerDutyNotifier(http.DefaultClient).Send(context.Background(), NotificationChannel{Type: ChannelPagerDuty}, testNotification(StateDown))
+	if err == nil {
+		t.Fatal("expected an error without routingKey")
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+var defaultRedactedHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+	"X-Api-Key", "X-Auth-Token", "X-Access-Token",
+}
+
+// Bare names match the key at any depth, $-paths only where they point.
+var defaultRedactedBodyPaths = []string{
+	"password", "passwd", "secret", "client_secret", "clientSecret", "token", "access_token",
+	"refresh_token", "id_token", "api_key", "apiKey", "private_key", "clientKey", "routingKey",
+}
+
+// Redactor masks credentials in documents before they are stored: values of
+// sensitive headers, sensitive fields of JSON bodies, the credentials of
+// check configs, and the same material when it shows up in free text such
+// as errors, urls and response samples.
+type Redactor struct {
+	headers   map[string]bool // lower case
+	keys      map[string]bool // bare body keys, lower case
+	paths     [][]string      // anchored body paths, "*" matches any key or index
+	textRules []textRule
+}
+
+type textRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+func NewRedactor(headers, bodyPaths []string) *Redactor {
+	r := &Redactor{
+		headers: make(map[string]bool),
+		keys:    make(map[string]bool),
+	}
+
+	var headerNames, keyNames []string
+	for _, header := range headers {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		r.headers[strings.ToLower(header)] = true
+		headerNames = append(headerNames, regexp.QuoteMeta(header))
+	}
+
+	for _, path := range bodyPaths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if !strings.HasPrefix(path, "$") {
+			r.keys[strings.ToLower(path)] = true
+			keyNames = append(keyNames, regexp.QuoteMeta(path))
+			continue
+		}
+		segments, err := parseRedactionPath(path)
+		if err != nil {
+			log.Printf("Ignoring redaction path: %v", err)
+			continue
+		}
+		r.paths = append(r.paths, segments)
+	}
+
+	if len(headerNames) > 0 {
+		r.textRules = append(r.textRules, textRule{
+			pattern:     regexp.MustCompile(`(?i)\b(` + strings.Join(headerNames, "|") + `)(["']?\s*[:=]\s*["']?)((?:bearer|basic)\s+)?[^\s"',;&}$][^\s"',;&}]*`),
+			replacement: "${1}${2}${3}" + redactedValue,
+		})
+	}
+	r.textRules = append(r.textRules, textRule{
+		pattern:     regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]{4,}`),
+		replacement: "${1} " + redactedValue,
+	})
+	if len(keyNames) > 0 {
+		keys := strings.Join(keyNames, "|")
+		r.textRules = append(r.textRules,
+			// JSON fragments, e.g. a truncated body in an error
+			textRule{
+				pattern:     regexp.MustCompile(`(?i)("(?:` + keys + `)"\s*:\s*)"(?:[^"\\$]|\\.)(?:[^"\\]|\\.)*"`),
+				replacement: `${1}"` + redactedValue + `"`,
+			},
+			// query strings and form bodies
+			textRule{
+				pattern:     regexp.MustCompile(`(?i)((?:^|[?&;\s])(?:` + keys + `)=)[^&\s"'$][^&\s"']*`),
+				replacement: "${1}" + redactedValue,
+			})
+	}
+
+	return r
+}
+
+// NewRedactorFromEnv extends the defaults with the comma separated
+// REDACT_HEADERS and REDACT_BODY_PATHS.
+func NewRedactorFromEnv() *Redactor {
+	headers := append([]string(nil), defaultRedactedHeaders...)
+	if extra := os.Getenv("REDACT_HEADERS"); extra != "" {
+		headers = append(headers, strings.Split(extra, ",")...)
+	}
+
+	paths := append([]string(nil), defaultRedactedBodyPaths...)
+	if extra := os.Getenv("REDACT_BODY_PATHS"); extra != "" {
+		paths = append(paths, strings.Split(extra, ",")...)
+	}
+
+	return NewRedactor(headers, paths)
+}
+
+// parseRedactionPath splits $.a.b, $.items[*].token and $['key'] into
+// segments.
+func parseRedactionPath(path string) ([]string, error) {
+	var segments []string
+	rest := path[1:]
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid path '%s'", path)
+			}
+			segments = append(segments, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid path '%s': unclosed bracket", path)
+			}
+			segments = append(segments, strings.Trim(strings.TrimSpace(rest[1:end]), `'"`))
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid path '%s'", path)
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("invalid path '%s'", path)
+	}
+	return segments, nil
+}
+
+// Redact returns a copy of document with credentials masked. Only documents
+// that carry request data are touched: urls, errors, headers and response
+// samples of logs, pings, deliveries and load test results. Anything else,
+// including the per-request load test logs, is stored as is.
+func (r *Redactor) Redact(document interface{}) (interface{}, error) {
+	switch document := document.(type) {
+	case HealthCheckLog:
+		return r.redactLog(document), nil
+	case HeartbeatPing:
+		document.Message = r.Text(document.Message)
+		return document, nil
+	case NotificationDelivery:
+		document.Target = r.Text(document.Target)
+		document.Error = r.textPointer(document.Error)
+		return document, nil
+	case LoadTestResult:
+		document.TestConfig.URL = r.Text(document.TestConfig.URL)
+		document.TestConfig.Headers = r.Headers(document.TestConfig.Headers)
+		document.TestConfig.Body = r.Body(document.TestConfig.Body)
+		return document, nil
+	case HealthCheckRevision:
+		document.Config = r.Config(document.Config)
+		return document, nil
+	}
+	return document, nil
+}
+
+// Config masks the credentials of a check config: sensitive headers, auth
+// secrets, notification keys, the transport's client key and the heartbeat
+// token. Everything else is kept verbatim so a revision can be written back.
+func (r *Redactor) Config(hc HealthCheck) HealthCheck {
+	hc.Headers = r.Headers(hc.Headers)
+
+	if hc.Auth != nil {
+		auth := *hc.Auth
+		auth.Password = maskCredential(auth.Password)
+		auth.Token = maskCredential(auth.Token)
+		auth.ClientSecret = maskCredential(auth.ClientSecret)
+		hc.Auth = &auth
+	}
+
+	if len(hc.Notifications) > 0 {
+		channels := make([]NotificationChannel, len(hc.Notifications))
+		for i, channel := range hc.Notifications {
+			channel.Secret = maskCredential(channel.Secret)
+			channel.RoutingKey = maskCredential(channel.RoutingKey)
+			channel.APIKey = maskCredential(channel.APIKey)
+			channel.Password = maskCredential(channel.Password)
+			channel.Headers = r.Headers(channel.Headers)
+			channels[i] = channel
+		}
+		hc.Notifications = channels
+	}
+
+	if hc.Transport != nil {
+		transport := *hc.Transport
+		transport.ClientKey = maskCredential(transport.ClientKey)
+		hc.Transport = &transport
+	}
+
+	if hc.Heartbeat != nil {
+		heartbeat := *hc.Heartbeat
+		heartbeat.Token = maskCredential(heartbeat.Token)
+		hc.Heartbeat = &heartbeat
+	}
+
+	if len(hc.Steps) > 0 {
+		steps := make([]HealthCheckStep, len(hc.Steps))
+		for i, step := range hc.Steps {
+			step.Headers = r.Headers(step.Headers)
+			steps[i] = step
+		}
+		hc.Steps = steps
+	}
+
+	return hc
+}
+
+// maskCredential keeps empty values and placeholders, which are references
+// rather than credentials
+func maskCredential(value string) string {
+	if value == "" || placeholderPattern.MatchString(value) {
+		return value
+	}
+	return redactedValue
+}
+
+func (r *Redactor) redactLog(logEntry HealthCheckLog) HealthCheckLog {
+	logEntry.Error = r.textPointer(logEntry.Error)
+	logEntry.Banner = r.Text(logEntry.Banner)
+	logEntry.Assertions = r.redactAssertions(logEntry.Assertions)
+
+	if len(logEntry.Attempts) > 0 {
+		attempts := make([]AttemptLog, len(logEntry.Attempts))
+		for i, attempt := range logEntry.Attempts {
+			attempt.Error = r.textPointer(attempt.Error)
+			attempts[i] = attempt
+		}
+		logEntry.Attempts = attempts
+	}
+
+	if len(logEntry.Steps) > 0 {
+		steps := make([]StepResult, len(logEntry.Steps))
+		for i, step := range logEntry.Steps {
+			step.URL = r.Text(step.URL)
+			step.Error = r.textPointer(step.Error)
+			step.Assertions = r.redactAssertions(step.Assertions)
+			steps[i] = step
+		}
+		logEntry.Steps = steps
+	}
+
+	if logEntry.Heartbeat != nil {
+		heartbeat := *logEntry.Heartbeat
+		heartbeat.Message = r.Text(heartbeat.Message)
+		logEntry.Heartbeat = &heartbeat
+	}
+
+	return logEntry
+}
+
+// redactAssertions masks the actual value of header and JSONPath assertions
+// on a sensitive target; other actual values are response samples. The
+// expected values are the check's own config and are kept.
+func (r *Redactor) redactAssertions(results []AssertionResult) []AssertionResult {
+	if len(results) == 0 {
+		return results
+	}
+
+	redacted := make([]AssertionResult, len(results))
+	for i, result := range results {
+		if result.Actual != "" && r.sensitiveTarget(result.Type, result.Target) {
+			result.Actual = redactedValue
+		} else {
+			result.Actual = r.Text(result.Actual)
+		}
+		redacted[i] = result
+	}
+	return redacted
+}
+
+func (r *Redactor) sensitiveTarget(kind, target string) bool {
+	if target == "" {
+		return false
+	}
+
+	switch kind {
+	case AssertionHeader:
+		return r.headers[strings.ToLower(target)]
+	case AssertionJSONPath:
+		if !strings.HasPrefix(target, "$") {
+			target = "$." + target
+		}
+		segments, err := parseRedactionPath(target)
+		return err == nil && r.sensitivePath(segments)
+	}
+	return false
+}
+
+func (r *Redactor) sensitivePath(segments []string) bool {
+	for _, segment := range segments {
+		if r.keys[strings.ToLower(segment)] {
+			return true
+		}
+	}
+	for _, pattern := range r.paths {
+		if len(pattern) > len(segments) {
+			continue
+		}
+		matched := true
+		for i, segment := range pattern {
+			if segment != "*" && segment != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Headers returns a copy of headers with the values of sensitive ones
+// masked, or headers itself when none is set
+func (r *Redactor) Headers(headers map[string]string) map[string]string {
+	var redacted map[string]string
+	for key, value := range headers {
+		if !r.headers[strings.ToLower(key)] || maskCredential(value) == value {
+			continue
+		}
+		if redacted == nil {
+			redacted = make(map[string]string, len(headers))
+			for key, value := range headers {
+				redacted[key] = value
+			}
+		}
+		redacted[key] = redactedValue
+	}
+	if redacted == nil {
+		return headers
+	}
+	return redacted
+}
+
+// Body masks sensitive fields of a JSON body in place, so key order,
+// spacing and escaping of everything else stay as they were. Anything that
+// is not a JSON object or array is treated as text, which covers form
+// encoded bodies.
+func (r *Redactor) Body(body string) string {
+	trimmed := strings.TrimSpace(body)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return r.Text(body)
+	}
+
+	masker := &jsonMasker{redactor: r, body: body, decoder: json.NewDecoder(strings.NewReader(body))}
+	if err := masker.value(nil, false); err != nil {
+		return r.Text(body)
+	}
+	// Trailing data means this was not a single JSON document
+	if _, err := masker.decoder.Token(); err != io.EOF {
+		return r.Text(body)
+	}
+	if len(masker.spans) == 0 {
+		return body
+	}
+
+	var redacted strings.Builder
+	last := 0
+	for _, span := range masker.spans {
+		redacted.WriteString(body[last:span[0]])
+		redacted.WriteString(`"` + redactedValue + `"`)
+		last = span[1]
+	}
+	redacted.WriteString(body[last:])
+	return redacted.String()
+}
+
+// jsonMasker walks a JSON body token by token and records the byte spans
+// of values at sensitive keys or paths
+type jsonMasker struct {
+	redactor *Redactor
+	body     string
+	decoder  *json.Decoder
+	spans    [][2]int
+}
+
+// value reads the value at path and everything below it; nothing is
+// recorded inside a value that is masked as a whole
+func (m *jsonMasker) value(path []string, masked bool) error {
+	token, err := m.decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	for index := 0; m.decoder.More(); index++ {
+		key := strconv.Itoa(index)
+		if delim == '{' {
+			token, err := m.decoder.Token()
+			if err != nil {
+				return err
+			}
+			key, _ = token.(string)
+		}
+
+		child := append(path[:len(path):len(path)], key)
+		if masked || !m.redactor.sensitivePath(child) {
+			if err := m.value(child, masked); err != nil {
+				return err
+			}
+			continue
+		}
+
+		start := m.valueStart()
+		if err := m.value(child, true); err != nil {
+			return err
+		}
+		end := int(m.decoder.InputOffset())
+		if m.maskable(m.body[start:end]) {
+			m.spans = append(m.spans, [2]int{start, end})
+		}
+	}
+
+	// The closing delimiter
+	_, err = m.decoder.Token()
+	return err
+}
+
+// valueStart is the offset of the next value, after the separator the
+// decoder has not consumed yet
+func (m *jsonMasker) valueStart() int {
+	offset := int(m.decoder.InputOffset())
+	for offset < len(m.body) && strings.IndexByte(" \t\r\n:,", m.body[offset]) != -1 {
+		offset++
+	}
+	return offset
+}
+
+// maskable leaves empty strings, nulls and placeholders alone
+func (m *jsonMasker) maskable(raw string) bool {
+	switch raw {
+	case `""`, "null":
+		return false
+	}
+	var value string
+	if json.Unmarshal([]byte(raw), &value) == nil && placeholderPattern.MatchString(value) {
+		return false
+	}
+	return true
+}
+
+func (r *Redactor) textPointer(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	redacted := r.Text(*s)
+	return &redacted
+}
+
+// Text masks credentials embedded in free text; values starting with $ are
+// left alone so ${secret:...} placeholders survive.
+func (r *Redactor) Text(s string) string {
+	if s == "" {
+		return s
+	}
+	for _, rule := range r.textRules {
+		s = rule.pattern.ReplaceAllString(s, rule.replacement)
+	}
+	return s
+}
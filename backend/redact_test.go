@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRedactConfigCredentials(t *testing.T) {
+	r := NewRedactor(defaultRedactedHeaders, defaultRedactedBodyPaths)
+
+	hc := HealthCheck{
+		Name:    "api",
+		URL:     "https://api.example.com",
+		Headers: map[string]string{"Authorization": "Bearer header-value", "Accept": "application/json"},
+		Auth:    &AuthConfig{Type: AuthOAuth2, ClientID: "client", ClientSecret: "cs-value", TokenURL: "https://idp/token"},
+		Notifications: []NotificationChannel{
+			{Type: ChannelWebhook, URL: "https://hooks.example.com", Secret: "hmac-value"},
+			{Type: ChannelPagerDuty, RoutingKey: "rk-value"},
+			{Type: ChannelOpsgenie, APIKey: "ak-value"},
+			{Type: ChannelEmail, Username: "alerts", Password: "smtp-value", To: []string{"oncall@example.com"}},
+		},
+		Transport: &TransportOptions{ClientCert: "-----BEGIN CERTIFICATE-----", ClientKey: "key-value"},
+		Heartbeat: &HeartbeatConfig{Token: "hb-value", Period: 60},
+		Steps: []HealthCheckStep{
+			{Name: "login", URL: "https://api.example.com/login", Headers: map[string]string{"X-Auth-Token": "step-value"}},
+		},
+	}
+
+	data, err := bson.MarshalExtJSON(r.Config(hc), false, false)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	stored := string(data)
+
+	for _, value := range []string{"header-value", "cs-value", "hmac-value", "rk-value", "ak-value", "smtp-value", "key-value", "hb-value", "step-value"} {
+		if strings.Contains(stored, value) {
+			t.Errorf("%s is stored in plain text: %s", value, stored)
+		}
+	}
+	for _, value := range []string{"client", "https://idp/token", "alerts", "-----BEGIN CERTIFICATE-----", "oncall@example.com", "application/json"} {
+		if !strings.Contains(stored, value) {
+			t.Errorf("%s was redacted: %s", value, stored)
+		}
+	}
+
+	// The live config is still used to run the check
+	if hc.Auth.ClientSecret != "cs-value" || hc.Notifications[0].Secret != "hmac-value" || hc.Headers["Authorization"] != "Bearer header-value" {
+		t.Errorf("the live config was modified: %+v", hc)
+	}
+}
+
+func TestRedactKeepsPlaceholdersAndEmptyValues(t *testing.T) {
+	r := NewRedactor(defaultRedactedHeaders, defaultRedactedBodyPaths)
+
+	hc := HealthCheck{
+		Headers: map[string]string{"Authorization": "Bearer ${secret:api-token}"},
+		Auth:    &AuthConfig{Type: AuthBasic, Username: "svc", Password: "${secret:svc-password}"},
+	}
+	config := r.Config(hc)
+
+	if config.Auth.Password != "${secret:svc-password}" || config.Headers["Authorization"] != "Bearer ${secret:api-token}" {
+		t.Errorf("placeholder was redacted: %+v %v", config.Auth, config.Headers)
+	}
+	if config.Auth.Token != "" {
+		t.Errorf("empty value was redacted: %q", config.Auth.Token)
+	}
+}
+
+func TestRedactBodyInPlace(t *testing.T) {
+	r := NewRedactor(defaultRedactedHeaders, append(defaultRedactedBodyPaths, "$.user.pin", "$.items[*].code"))
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"nothing to mask", `{"z":1, "a":"<b>&</b>", "list":[1,2]}`, `{"z":1, "a":"<b>&</b>", "list":[1,2]}`},
+		{"key at any depth", `{"name":"ann","auth":{"password" : "pw","user":"ann"}}`, `{"name":"ann","auth":{"password" : "[REDACTED]","user":"ann"}}`},
+		{"anchored path", `{"user":{"pin":1234,"name":"ann"},"pin":5}`, `{"user":{"pin":"[REDACTED]","name":"ann"},"pin":5}`},
+		{"array wildcard", `{"items":[{"code":"a"},{"code":"b","id":2}]}`, `{"items":[{"code":"[REDACTED]"},{"code":"[REDACTED]","id":2}]}`},
+		{"whole object", `{"token":{"value":"t","ttl":60},"ok":true}`, `{"token":"[REDACTED]","ok":true}`},
+		{"placeholders, empty values and nulls", `{"password":"${secret:db}","token":"","secret":null}`, `{"password":"${secret:db}","token":"","secret":null}`},
+		{"escaped key order is kept", "{\n  \"b\": \"\\u003c\",\n  \"apiKey\": \"k\"\n}", "{\n  \"b\": \"\\u003c\",\n  \"apiKey\": \"[REDACTED]\"\n}"},
+		{"form body", `user=ann&password=pw`, `user=ann&password=[REDACTED]`},
+		{"invalid json", `{"password":"pw"`, `{"password":"[REDACTED]"`},
+	}
+
+	for _, test := range tests {
+		if got := r.Body(test.body); got != test.want {
+			t.Errorf("%s: Body() = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRedactLog(t *testing.T) {
+	r := NewRedactor(defaultRedactedHeaders, defaultRedactedBodyPaths)
+
+	failure := "GET https://api.example.com/?token=abcdef: Authorization: Bearer abcdef rejected"
+	logEntry := HealthCheckLog{
+		Error: &failure,
+		Assertions: []AssertionResult{
+			{Type: AssertionHeader, Target: "Authorization", Expected: "^Bearer .+$", Actual: "Bearer abcdef"},
+			{Type: AssertionJSONPath, Target: "data.token", Expected: "x", Actual: "abcdef"},
+			{Type: AssertionBody, Operator: OperatorMatches, Expected: `token=\w+`, Actual: `{"ok":true}`},
+		},
+		Steps: []StepResult{{Name: "login", URL: "https://api.example.com/login?api_key=abcdef", Error: &failure}},
+	}
+
+	redacted, err := r.Redact(logEntry)
+	if err != nil {
+		t.Fatalf("redact: %v", err)
+	}
+	stored := redacted.(HealthCheckLog)
+
+	if strings.Contains(*stored.Error, "abcdef") || strings.Contains(*stored.Steps[0].Error, "abcdef") || strings.Contains(stored.Steps[0].URL, "abcdef") {
+		t.Errorf("credentials in errors or urls: %+v", stored)
+	}
+	for i, want := range []string{redactedValue, redactedValue, `{"ok":true}`} {
+		if stored.Assertions[i].Actual != want {
+			t.Errorf("assertion %d: actual = %q, want %q", i, stored.Assertions[i].Actual, want)
+		}
+		if stored.Assertions[i].Expected != logEntry.Assertions[i].Expected {
+			t.Errorf("assertion %d: expected changed to %q", i, stored.Assertions[i].Expected)
+		}
+	}
+
+	// The caller's log is still used for notifications and state
+	if *logEntry.Error != failure || logEntry.Assertions[0].Actual != "Bearer abcdef" || logEntry.Steps[0].URL != "https://api.example.com/login?api_key=abcdef" {
+		t.Errorf("the original log was modified: %+v", logEntry)
+	}
+}
+
+func TestRedactLoadTestResult(t *testing.T) {
+	r := NewRedactor(defaultRedactedHeaders, defaultRedactedBodyPaths)
+
+	headers := map[string]string{"Authorization": "Bearer abcdef", "Accept": "application/json", "X-Api-Key": "${secret:api}"}
+	result := LoadTestResult{TestConfig: LoadTestConfig{
+		URL:     "https://api.example.com/?access_token=abcdef",
+		Headers: headers,
+		Body:    `{"username":"ann","password":"pw"}`,
+	}}
+
+	redacted, err := r.Redact(result)
+	if err != nil {
+		t.Fatalf("redact: %v", err)
+	}
+	config := redacted.(LoadTestResult).TestConfig
+
+	if config.URL != "https://api.example.com/?access_token=[REDACTED]" {
+		t.Errorf("url = %s", config.URL)
+	}
+	if config.Headers["Authorization"] != redactedValue || config.Headers["Accept"] != "application/json" || config.Headers["X-Api-Key"] != "${secret:api}" {
+		t.Errorf("headers = %v", config.Headers)
+	}
+	if config.Body != `{"username":"ann","password":"[REDACTED]"}` {
+		t.Errorf("body = %s", config.Body)
+	}
+	if headers["Authorization"] != "Bearer abcdef" {
+		t.Error("the request headers were modified")
+	}
+
+	// Per-request load test logs are written as they are
+	loadTestLog := LoadTestLog{URL: "https://api.example.com/?access_token=abcdef"}
+	if stored, _ := r.Redact(loadTestLog); stored.(LoadTestLog).URL != loadTestLog.URL {
+		t.Errorf("load test log url = %s", stored.(LoadTestLog).URL)
+	}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var defaultReportWindows = []string{"24h", "7d", "30d"}
+
+// SLOConfig is the share of probes that must be good. With a latency
+// threshold a probe is only good when it succeeded within it, so
+// "p95 < 300ms" is target 95 with latencyThreshold 300.
+type SLOConfig struct {
+	Target           float64 `bson:"target" json:"target"`                                         // percent, e.g. 99.9
+	LatencyThreshold int     `bson:"latencyThreshold,omitempty" json:"latencyThreshold,omitempty"` // ms
+
+	BurnRateAlerts []BurnRateRule `bson:"burnRateAlerts,omitempty" json:"burnRateAlerts,omitempty"` // SRE workbook defaults when empty
+}
+
+type UptimeReport struct {
+	Check       string         `json:"check"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+	SLO         *SLOConfig     `json:"slo,omitempty"`
+	Windows     []WindowReport `json:"windows"`
+}
+
+type WindowReport struct {
+	Window           string     `json:"window"`
+	From             time.Time  `json:"from"`
+	To               time.Time  `json:"to"`
+	TotalProbes      int64      `json:"totalProbes"`
+	SuccessfulProbes int64      `json:"successfulProbes"`
+	FailedProbes     int64      `json:"failedProbes"`
+	Uptime           *float64   `json:"uptime,omitempty"` // percent of successful probes
+	AverageTime      float64    `json:"averageTime"`      // ms
+	MinTime          int64      `json:"minTime"`          // ms
+	MaxTime          int64      `json:"maxTime"`          // ms
+	P95Time          float64    `json:"p95Time"`          // ms
+	Incidents        int        `json:"incidents"`        // transitions to DOWN
+	Downtime         float64    `json:"downtime"`         // seconds spent DOWN
+	MTTR             *float64   `json:"mttr,omitempty"`   // seconds, over incidents that recovered
+	MTBF             *float64   `json:"mtbf,omitempty"`   // seconds of uptime per incident
+	SLO              *SLOReport `json:"slo,omitempty"`
+}
+
+type SLOReport struct {
+	Target               float64 `json:"target"`
+	SLI                  float64 `json:"sli"` // percent of good probes
+	GoodProbes           int64   `json:"goodProbes"`
+	ErrorBudget          float64 `json:"errorBudget"`          // bad probes the target allows in the window
+	ErrorBudgetRemaining float64 `json:"errorBudgetRemaining"` // percent, negative once exhausted
+	BurnRate             float64 `json:"burnRate"`             // 1 spends the budget exactly over the window
+}
+
+// parseWindow accepts Go durations plus day and week suffixes ("7d", "2w")
+func parseWindow(window string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(window, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(window, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	if unit > 0 {
+		n, err := strconv.ParseFloat(window[:len(window)-1], 64)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window '%s'", window)
+		}
+		return time.Duration(n * float64(unit)), nil
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid window '%s'", window)
+	}
+	return d, nil
+}
+
+func timeRange(from, to time.Time) bson.M {
+	return bson.M{"timestamp": bson.M{"$gte": from, "$lt": to}}
+}
+
+// reportFilter selects the results between from and to that count towards
+// uptime and SLOs; results recorded during maintenance don't.
+func reportFilter(from, to time.Time) bson.M {
+	filter := timeRange(from, to)
+	filter["inMaintenance"] = bson.M{"$ne": true}
+	return filter
+}
+
+// windowStats is LogStats plus the p95, computed by $percentile (MongoDB 7.0)
+// so the response times never leave the database.
+type windowStats struct {
+	LogStats `bson:",inline"`
+	P95Time  []float64 `bson:"p95Time"`
+}
+
+func (m *HealthCheckManager) getWindowStats(ctx context.Context, collectionName string, from, to time.Time) (*windowStats, error) {
+	pipeline := []bson.M{
+		{
+			"$match": reportFilter(from, to),
+		},
+		{
+			"$group": bson.M{
+				"_id":                nil,
+				"totalRequests":      bson.M{"$sum": 1},
+				"successfulRequests": bson.M{"$sum": bson.M{"$cond": []interface{}{"$success", 1, 0}}},
+				"failedRequests":     bson.M{"$sum": bson.M{"$cond": []interface{}{"$success", 0, 1}}},
+				"avgTime":            bson.M{"$avg": "$responseTime"},
+				"minTime":            bson.M{"$min": "$responseTime"},
+				"maxTime":            bson.M{"$max": "$responseTime"},
+				"p95Time": bson.M{"$percentile": bson.M{
+					"input":  "$responseTime",
+					"p":      []float64{0.95},
+					"method": "approximate",
+				}},
+			},
+		},
+	}
+
+	cursor, err := m.mongoHelper.GetCollection(collectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error in aggregation: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []windowStats
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("error decoding results: %w", err)
+	}
+	if len(results) == 0 {
+		return &windowStats{}, nil
+	}
+	return &results[0], nil
+}
+
+// countGoodProbes returns good and total probes between from and to
+func (m *HealthCheckManager) countGoodProbes(ctx context.Context, name string, slo SLOConfig, from, to time.Time) (int64, int64, error) {
+	var good interface{} = "$success"
+	if slo.LatencyThreshold > 0 {
+		good = bson.M{"$and": []interface{}{"$success", bson.M{"$lte": []interface{}{"$responseTime", slo.LatencyThreshold}}}}
+	}
+
+	pipeline := []bson.M{
+		{
+			"$match": reportFilter(from, to),
+		},
+		{
+			"$group": bson.M{
+				"_id":   nil,
+				"total": bson.M{"$sum": 1},
+				"good":  bson.M{"$sum": bson.M{"$cond": []interface{}{good, 1, 0}}},
+			},
+		},
+	}
+
+	cursor, err := m.mongoHelper.GetCollection(healthCheckCollection(name)).Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error in aggregation: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Total int64 `bson:"total"`
+		Good  int64 `bson:"good"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, 0, fmt.Errorf("error decoding results: %w", err)
+	}
+	if len(results) == 0 {
+		return 0, 0, nil
+	}
+	return results[0].Good, results[0].Total, nil
+}
+
+// validate rejects targets without an error budget; at 100% every bad probe
+// is an infinite burn rate, which no threshold can describe
+func (slo SLOConfig) validate() error {
+	if slo.Target <= 0 || slo.Target >= 100 {
+		return fmt.Errorf("slo target must be above 0 and below 100, got %g", slo.Target)
+	}
+	return nil
+}
+
+// NewSLOReport turns good/total probe counts into error budget figures
+func NewSLOReport(slo SLOConfig, good, total int64) *SLOReport {
+	report := &SLOReport{
+		Target:               slo.Target,
+		SLI:                  100,
+		GoodProbes:           good,
+		ErrorBudgetRemaining: 100,
+	}
+
+	allowedBadRatio := 1 - slo.Target/100
+	report.ErrorBudget = allowedBadRatio * float64(total)
+
+	if total == 0 {
+		return report
+	}
+
+	bad := float64(total - good)
+	report.SLI = float64(good) / float64(total) * 100
+
+	if allowedBadRatio <= 0 {
+		if bad > 0 {
+			report.ErrorBudgetRemaining = -100
+		}
+		return report
+	}
+
+	report.BurnRate = (bad / float64(total)) / allowedBadRatio
+	report.ErrorBudgetRemaining = (1 - bad/report.ErrorBudget) * 100
+
+	return report
+}
+
+func (m *HealthCheckManager) buildWindowReport(ctx context.Context, hc HealthCheck, slo *SLOConfig, window string, now time.Time) (WindowReport, error) {
+	duration, err := parseWindow(window)
+	if err != nil {
+		return WindowReport{}, err
+	}
+
+	report := WindowReport{
+		Window: window,
+		From:   now.Add(-duration),
+		To:     now,
+	}
+
+	stats, err := m.getWindowStats(ctx, healthCheckCollection(hc.Name), report.From, report.To)
+	if err != nil {
+		return report, err
+	}
+
+	report.TotalProbes = stats.TotalRequests
+	report.SuccessfulProbes = stats.SuccessfulRequests
+	report.FailedProbes = stats.FailedRequests
+	report.AverageTime = stats.AverageTime
+	report.MinTime = stats.MinTime
+	report.MaxTime = stats.MaxTime
+	if len(stats.P95Time) > 0 {
+		report.P95Time = stats.P95Time[0]
+	}
+
+	if stats.TotalRequests > 0 {
+		uptime := float64(stats.SuccessfulRequests) / float64(stats.TotalRequests) * 100
+		report.Uptime = &uptime
+	}
+
+	if err := m.addIncidentStats(ctx, hc, &report); err != nil {
+		return report, err
+	}
+
+	if slo != nil {
+		good, total, err := m.countGoodProbes(ctx, hc.Name, *slo, report.From, report.To)
+		if err != nil {
+			return report, err
+		}
+		report.SLO = NewSLOReport(*slo, good, total)
+	}
+
+	return report, nil
+}
+
+// addIncidentStats replays the state transitions of the window; the last
+// transition before it gives the state the window starts in.
+func (m *HealthCheckManager) addIncidentStats(ctx context.Context, hc HealthCheck, report *WindowReport) error {
+	collection := m.mongoHelper.GetCollection(stateTransitionsCollection)
+
+	state := StateUnknown
+	var before StateTransition
+	err := collection.FindOne(ctx, bson.M{
+		"checkId":   hc.ID,
+		"timestamp": bson.M{"$lt": report.From},
+	}, options.FindOne().SetSort(bson.M{"timestamp": -1})).Decode(&before)
+	if err == nil {
+		state = before.To
+	} else if err != mongo.ErrNoDocuments {
+		return fmt.Errorf("error finding transitions: %w", err)
+	}
+
+	filter := timeRange(report.From, report.To)
+	filter["checkId"] = hc.ID
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"timestamp": 1}))
+	if err != nil {
+		return fmt.Errorf("error finding transitions: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var transitions []StateTransition
+	if err := cursor.All(ctx, &transitions); err != nil {
+		return fmt.Errorf("error decoding transitions: %w", err)
+	}
+
+	var (
+		downSince  time.Time
+		downtime   time.Duration
+		repaired   time.Duration
+		recoveries int
+	)
+	if state == StateDown {
+		downSince = report.From
+	}
+
+	for _, transition := range transitions {
+		switch {
+		case transition.To == StateDown && state != StateDown:
+			report.Incidents++
+			downSince = transition.Timestamp
+		case transition.To != StateDown && state == StateDown:
+			outage := transition.Timestamp.Sub(downSince)
+			downtime += outage
+			repaired += outage
+			recoveries++
+		}
+		state = transition.To
+	}
+
+	if state == StateDown {
+		downtime += report.To.Sub(downSince)
+	}
+
+	report.Downtime = downtime.Seconds()
+
+	if recoveries > 0 {
+		mttr := repaired.Seconds() / float64(recoveries)
+		report.MTTR = &mttr
+	}
+	if report.Incidents > 0 {
+		mtbf := (report.To.Sub(report.From) - downtime).Seconds() / float64(report.Incidents)
+		report.MTBF = &mtbf
+	}
+
+	return nil
+}
+
+// reportSLO applies the target and latency query parameters to the check's
+// SLO; latency alone tightens the check's own target.
+func reportSLO(slo *SLOConfig, query url.Values) (*SLOConfig, error) {
+	if target := query.Get("target"); target != "" {
+		value, err := strconv.ParseFloat(target, 64)
+		if err != nil {
+			return nil, fmt.Errorf("target must be a percentage between 0 and 100")
+		}
+		slo = &SLOConfig{Target: value}
+		if err := slo.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if latency := query.Get("latency"); latency != "" {
+		value, err := strconv.Atoi(latency)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("latency must be a number of milliseconds")
+		}
+		if slo == nil {
+			return nil, fmt.Errorf("latency needs a target, the check has no slo")
+		}
+		override := *slo
+		override.LatencyThreshold = value
+		slo = &override
+	}
+
+	return slo, nil
+}
+
+// HandleReport serves GET /healthchecks/{name}/report. The windows query
+// parameter takes a comma separated list (default 24h,7d,30d); target and
+// latency override the check's SLO for ad hoc questions.
+func (m *HealthCheckManager) HandleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+
+	var hc HealthCheck
+	err := m.mongoHelper.GetCollection("healthchecks").FindOne(r.Context(), bson.M{"name": name}).Decode(&hc)
+	if err == mongo.ErrNoDocuments {
+		JSONError(w, fmt.Sprintf("Health check '%s' not found", name), http.StatusNotFound)
+		return
+	} else if err != nil {
+		JSONError(w, "Error loading health check", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	slo, err := reportSLO(hc.SLO, query)
+	if err != nil {
+		JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	windows := append([]string(nil), defaultReportWindows...)
+	if param := query.Get("windows"); param != "" {
+		windows = strings.Split(param, ",")
+	}
+	for i, window := range windows {
+		windows[i] = strings.TrimSpace(window)
+		if _, err := parseWindow(windows[i]); err != nil {
+			JSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	report := UptimeReport{
+		Check:       hc.Name,
+		GeneratedAt: now,
+		SLO:         slo,
+	}
+
+	for _, window := range windows {
+		windowReport, err := m.buildWindowReport(r.Context(), hc, slo, window, now)
+		if err != nil {
+			JSONError(w, fmt.Sprintf("Error building report: %v", err), http.StatusInternalServerError)
+			return
+		}
+		report.Windows = append(report.Windows, windowReport)
+	}
+
+	JSONResponse(w, report, http.StatusOK)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		window string
+		want   time.Duration
+		valid  bool
+	}{
+		{"90m", 90 * time.Minute, true},
+		{"24h", 24 * time.Hour, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"1.5d", 36 * time.Hour, true},
+		{"2w", 14 * 24 * time.Hour, true},
+		{"0d", 0, false},
+		{"-1h", 0, false},
+		{"d", 0, false},
+		{"month", 0, false},
+	}
+
+	for _, test := range tests {
+		got, err := parseWindow(test.window)
+		if got != test.want || (err == nil) != test.valid {
+			t.Errorf("parseWindow(%q) = %v, %v", test.window, got, err)
+		}
+	}
+}
+
+func TestNewSLOReport(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    float64
+		good      int64
+		total     int64
+		sli       float64
+		budget    float64
+		remaining float64
+		burnRate  float64
+	}{
+		{"no probes", 99.9, 0, 0, 100, 0, 100, 0},
+		{"all good", 99.9, 1000, 1000, 100, 1, 100, 0},
+		{"budget spent exactly", 99.9, 999, 1000, 99.9, 1, 0, 1},
+		{"burning ten times too fast", 99, 900, 1000, 90, 10, -900, 10},
+		{"half the budget left", 95, 975, 1000, 97.5, 50, 50, 0.5},
+	}
+
+	for _, test := range tests {
+		report := NewSLOReport(SLOConfig{Target: test.target}, test.good, test.total)
+		got := []float64{report.SLI, report.ErrorBudget, report.ErrorBudgetRemaining, report.BurnRate}
+		want := []float64{test.sli, test.budget, test.remaining, test.burnRate}
+		for i := range got {
+			if math.Abs(got[i]-want[i]) > 1e-9 {
+				t.Errorf("%s: report = %+v", test.name, report)
+				break
+			}
+		}
+	}
+}
+
+func TestReportSLO(t *testing.T) {
+	checkSLO := &SLOConfig{Target: 99.9, LatencyThreshold: 500}
+
+	tests := []struct {
+		name  string
+		slo   *SLOConfig
+		query string
+		want  *SLOConfig
+		err   string
+	}{
+		{"check slo", checkSLO, "", checkSLO, ""},
+		{"no slo", nil, "", nil, ""},
+		{"target overrides", checkSLO, "target=99", &SLOConfig{Target: 99}, ""},
+		{"target and latency", nil, "target=95&latency=300", &SLOConfig{Target: 95, LatencyThreshold: 300}, ""},
+		{"latency tightens the check slo", checkSLO, "latency=200", &SLOConfig{Target: 99.9, LatencyThreshold: 200}, ""},
+		{"latency without any target", nil, "latency=200", nil, "latency needs a target"},
+		{"bad latency", checkSLO, "latency=-1", nil, "latency must be a number of milliseconds"},
+		{"bad target", checkSLO, "target=high", nil, "target must be a percentage"},
+		{"target above 100", checkSLO, "target=101", nil, "slo target must be above 0 and below 100"},
+		{"target of 100", checkSLO, "target=100", nil, "slo target must be above 0 and below 100"},
+	}
+
+	for _, test := range tests {
+		query, _ := url.ParseQuery(test.query)
+		got, err := reportSLO(test.slo, query)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: err = %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: slo = %+v, %v", test.name, got, err)
+		}
+	}
+	if checkSLO.LatencyThreshold != 500 {
+		t.Errorf("check slo was modified: %+v", checkSLO)
+	}
+}
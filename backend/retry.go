@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	ErrorClassTimeout    = "timeout"
+	ErrorClassConnection = "connection"
+	ErrorClassDNS        = "dns"
+	ErrorClassTLS        = "tls"
+	ErrorClassStatus     = "status"
+	ErrorClassAssertion  = "assertion"
+	ErrorClassConfig     = "config" // the check itself is invalid; never retried
+	ErrorClassOther      = "other"
+
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultRetryMultiplier = 2.0
+	maxRetryBackoff        = 30 * time.Second
+)
+
+var defaultRetryOn = []string{ErrorClassTimeout, ErrorClassConnection, ErrorClassDNS}
+
+type RetryPolicy struct {
+	Count      int      `bson:"count"`
+	Backoff    int      `bson:"backoff,omitempty"`    // ms before the first retry
+	Multiplier float64  `bson:"multiplier,omitempty"` // backoff growth per retry
+	On         []string `bson:"on,omitempty"`         // error classes to retry, defaults to timeout, connection and dns
+}
+
+type AttemptLog struct {
+	Attempt      int       `bson:"attempt"`
+	Timestamp    time.Time `bson:"timestamp"`
+	StatusCode   int       `bson:"statusCode"`
+	ResponseTime int64     `bson:"responseTime"`
+	Error        *string   `bson:"error,omitempty"`
+	ErrorClass   string    `bson:"errorClass"`
+}
+
+func (p *RetryPolicy) shouldRetry(errorClass string) bool {
+	on := p.On
+	if len(on) == 0 {
+		on = defaultRetryOn
+	}
+	for _, class := range on {
+		if class == errorClass {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) initialBackoff() time.Duration {
+	if p.Backoff > 0 {
+		return time.Duration(p.Backoff) * time.Millisecond
+	}
+	return defaultRetryBackoff
+}
+
+func (p *RetryPolicy) nextBackoff(current time.Duration) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultRetryMultiplier
+	}
+	next := time.Duration(float64(current) * multiplier)
+	if next > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return next
+}
+
+// probeWithRetry runs the probe until it succeeds, the retry budget is
+// spent or the failure is of a class the policy does not retry. Earlier
+// failed attempts are kept on the returned log entry.
+func (m *HealthCheckManager) probeWithRetry(ctx context.Context, hc HealthCheck) HealthCheckLog {
+	policy := hc.Retry
+
+	var (
+		attempts []AttemptLog
+		backoff  time.Duration
+	)
+	if policy != nil {
+		backoff = policy.initialBackoff()
+	}
+
+	for attempt := 1; ; attempt++ {
+		logEntry := m.probe(ctx, hc)
+		logEntry.Attempt = attempt
+		logEntry.Retries = attempt - 1
+		logEntry.Attempts = attempts
+
+		if logEntry.Success || policy == nil || attempt > policy.Count {
+			return logEntry
+		}
+
+		class := failureClass(logEntry)
+		if !policy.shouldRetry(class) {
+			return logEntry
+		}
+
+		attempts = append(attempts, AttemptLog{
+			Attempt:      attempt,
+			Timestamp:    logEntry.Timestamp,
+			StatusCode:   logEntry.StatusCode,
+			ResponseTime: logEntry.ResponseTime,
+			Error:        logEntry.Error,
+			ErrorClass:   class,
+		})
+
+		log.Printf("[%s] Attempt %d/%d failed (%s), retrying in %v", hc.Name, attempt, policy.Count+1, class, backoff)
+
+		if !sleepContext(ctx, backoff) {
+			return logEntry
+		}
+		backoff = policy.nextBackoff(backoff)
+	}
+}
+
+func failureClass(logEntry HealthCheckLog) string {
+	if logEntry.ErrorClass != "" {
+		return logEntry.ErrorClass
+	}
+	for _, result := range logEntry.Assertions {
+		if !result.Passed && result.Type == AssertionStatusCode {
+			return ErrorClassStatus
+		}
+	}
+	return ErrorClassAssertion
+}
+
+func classifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ErrorClassTimeout
+		}
+		return ErrorClassDNS
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorClassTimeout
+	}
+
+	var (
+		verifyErr   *tls.CertificateVerificationError
+		recordErr   tls.RecordHeaderError
+		alertErr    tls.AlertError
+		unknownAuth x509.UnknownAuthorityError
+		invalidCert x509.CertificateInvalidError
+		hostnameErr x509.HostnameError
+	)
+	if errors.As(err, &verifyErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &unknownAuth) || errors.As(err, &invalidCert) || errors.As(err, &hostnameErr) {
+		return ErrorClassTLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassConnection
+	}
+
+	return ErrorClassOther
+}
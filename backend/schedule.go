@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // the backend image is built FROM scratch
+)
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDay    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronWeekday = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// CronSchedule is a standard five field cron expression
+// (minute hour day-of-month month day-of-week) evaluated in a timezone.
+type CronSchedule struct {
+	Expression string
+	Location   *time.Location
+
+	minute, hour, day, month, weekday uint64
+	dayStar, weekdayStar              bool
+}
+
+func ParseCronSchedule(expression, timezone string) (*CronSchedule, error) {
+	location := time.UTC
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone '%s': %w", timezone, err)
+		}
+	}
+
+	spec := strings.TrimSpace(expression)
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression '%s': expected 5 fields, got %d", expression, len(fields))
+	}
+
+	schedule := &CronSchedule{
+		Expression: expression,
+		Location:   location,
+	}
+
+	var err error
+	targets := []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&schedule.minute, cronMinute},
+		{&schedule.hour, cronHour},
+		{&schedule.day, cronDay},
+		{&schedule.month, cronMonth},
+		{&schedule.weekday, cronWeekday},
+	}
+	for i, target := range targets {
+		if *target.bits, err = parseCronField(fields[i], target.field); err != nil {
+			return nil, fmt.Errorf("invalid cron expression '%s': %w", expression, err)
+		}
+	}
+
+	// 7 is an alias for Sunday
+	if schedule.weekday&(1<<7) != 0 {
+		schedule.weekday |= 1
+	}
+
+	// As in Vixie cron a day field is unrestricted when it starts with a
+	// star, steps like */2 included, or when it lists every value anyway
+	schedule.dayStar = isCronStar(fields[2]) || schedule.day == cronDay.all()
+	schedule.weekdayStar = isCronStar(fields[4]) || schedule.weekday|1<<7 == cronWeekday.all()
+
+	return schedule, nil
+}
+
+func isCronStar(expr string) bool {
+	return strings.HasPrefix(expr, "*") || strings.HasPrefix(expr, "?")
+}
+
+// all is the set of every value of the field
+func (f cronField) all() uint64 {
+	var bits uint64
+	for v := f.min; v <= f.max; v++ {
+		bits |= 1 << uint(v)
+	}
+	return bits
+}
+
+// parseCronField handles lists, ranges, steps and names: "*/5", "9-17", "mon-fri", "0,30"
+func parseCronField(expr string, field cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(expr, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field '%s'", field.name, part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		low, high := field.min, field.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = parseCronValue(bounds[0], field); err != nil {
+				return 0, err
+			}
+			if high, err = parseCronValue(bounds[1], field); err != nil {
+				return 0, err
+			}
+		default:
+			value, err := parseCronValue(rangePart, field)
+			if err != nil {
+				return 0, err
+			}
+			low = value
+			if !strings.Contains(part, "/") {
+				high = value
+			}
+		}
+
+		if low > high {
+			return 0, fmt.Errorf("invalid range in %s field '%s'", field.name, part)
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(s string, field cronField) (int, error) {
+	if value, ok := field.names[strings.ToLower(s)]; ok {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(s)
+	if err != nil || value < field.min || value > field.max {
+		return 0, fmt.Errorf("invalid %s '%s' (allowed %d-%d)", field.name, s, field.min, field.max)
+	}
+
+	return value, nil
+}
+
+// Next returns the first matching minute strictly after t, or the zero time
+// when nothing matches within five years (e.g. "0 0 30 2 *"). Wall clock
+// times skipped by a DST change never match, repeated ones match twice.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.Location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.Location)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.Location)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			// elapsed time rather than time.Date so DST changes cannot move us backwards
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches follows cron semantics: when both day fields are restricted a
+// day matching either of them is enough.
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	day := s.day&(1<<uint(t.Day())) != 0
+	weekday := s.weekday&(1<<uint(t.Weekday())) != 0
+
+	if s.dayStar || s.weekdayStar {
+		return day && weekday
+	}
+	return day || weekday
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		timezone   string
+		err        string
+	}{
+		{"* * * *", "", "expected 5 fields, got 4"},
+		{"0 9 * * *", "Mars/Olympus", "invalid timezone 'Mars/Olympus'"},
+		{"60 * * * *", "", "invalid minute '60' (allowed 0-59)"},
+		{"0 24 * * *", "", "invalid hour '24' (allowed 0-23)"},
+		{"0 0 0 * *", "", "invalid day of month '0' (allowed 1-31)"},
+		{"0 0 * foo *", "", "invalid month 'foo' (allowed 1-12)"},
+		{"*/0 * * * *", "", "invalid step in minute field '*/0'"},
+		{"0 17-9 * * *", "", "invalid range in hour field '17-9'"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseCronSchedule(test.expression, test.timezone)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseCronSchedule(%q, %q) error = %v, want %q", test.expression, test.timezone, err, test.err)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	tests := []struct {
+		expression string
+		timezone   string
+		from       time.Time
+		want       time.Time
+	}{
+		{"*/15 * * * *", "", time.Date(2024, 3, 1, 10, 7, 30, 0, time.UTC), time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC)},
+		{"*/15 * * * *", "", time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC), time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{"0,30 9-17/4 * * *", "", time.Date(2024, 3, 1, 9, 45, 0, 0, time.UTC), time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", "", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)},
+		{"0 7 * * 7", "", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), time.Date(2024, 3, 3, 7, 0, 0, 0, time.UTC)},
+		{"@daily", "", time.Date(2024, 3, 1, 23, 59, 30, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 JAN-MAR/2 *", "", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", "", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", "", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+		// both day fields restricted: the 13th or any Friday
+		{"0 0 13 * fri", "", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", "", time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)},
+		// one day field restricted: only that one applies
+		{"0 0 13 * *", "", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 ? * fri", "", time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		// stepped stars and full ranges count as unrestricted: odd days that are Mondays
+		{"0 0 */2 * mon", "", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 0-6", "", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 1-7", "", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1-31 * fri", "", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * *", "Europe/Berlin", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)},
+		// 02:30 does not exist on the day clocks spring forward
+		{"30 2 * * *", "America/New_York", time.Date(2024, 3, 9, 12, 0, 0, 0, newYork), time.Date(2024, 3, 11, 2, 30, 0, 0, newYork)},
+		{"30 3 * * *", "America/New_York", time.Date(2024, 3, 9, 12, 0, 0, 0, newYork), time.Date(2024, 3, 10, 3, 30, 0, 0, newYork)},
+	}
+
+	for _, test := range tests {
+		schedule, err := ParseCronSchedule(test.expression, test.timezone)
+		if err != nil {
+			t.Fatalf("ParseCronSchedule(%q): %v", test.expression, err)
+		}
+		if got := schedule.Next(test.from); !got.Equal(test.want) {
+			t.Errorf("%q from %v: next = %v, want %v", test.expression, test.from, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	minCheckInterval  = 100 * time.Millisecond
+	idleSchedulerWait = time.Minute
+)
+
+// ScheduledCheck is a loaded health check together with its place in the
+// scheduler queue and its runtime state.
+type ScheduledCheck struct {
+	HealthCheck HealthCheck
+	Cron        *CronSchedule
+	NextRun     time.Time // slot plus jitter
+	State       *HealthCheckState
+
+	slot  time.Time // unjittered, following runs are computed from it so they don't drift
+	index int       // position in the queue, -1 when not queued
+
+	inFlight    int  // queued for or running on a worker
+	pending     bool // a run is waiting for the one in flight (overlap: queue)
+	runs        int64
+	skipped     int64
+	lastSkipped time.Time
+
+	firingBurnRules map[string]bool // burn rate rule -> firing
+	heartbeat       *heartbeatState
+}
+
+// period is the interval between runs; intervalMs allows sub-second checks
+func (hc HealthCheck) period() time.Duration {
+	period := time.Duration(hc.Interval) * time.Second
+	if hc.IntervalMs > 0 {
+		period = time.Duration(hc.IntervalMs) * time.Millisecond
+	}
+	switch {
+	case period == 0 && hc.Type == CheckTypeHeartbeat:
+		period = defaultHeartbeatEvaluation
+	case period < minCheckInterval:
+		// including checks without an interval, they run as often as allowed
+		period = minCheckInterval
+	}
+	return period
+}
+
+func newScheduledCheck(hc HealthCheck, now time.Time) *ScheduledCheck {
+	check := &ScheduledCheck{
+		State:     NewHealthCheckState(),
+		index:     -1,
+		heartbeat: newHeartbeatState(now),
+	}
+	check.configure(hc, now)
+	return check
+}
+
+// configure applies a (possibly changed) configuration and picks the first
+// run. Interval checks start at a random offset within their period so that
+// checks loaded together don't all fire at once. A broken cron expression
+// is logged and the check falls back to its interval.
+func (c *ScheduledCheck) configure(hc HealthCheck, now time.Time) {
+	c.HealthCheck = hc
+	c.Cron = nil
+	c.slot = time.Time{}
+	c.NextRun = time.Time{}
+
+	if hc.Schedule != "" {
+		cron, err := ParseCronSchedule(hc.Schedule, hc.Timezone)
+		if err == nil {
+			c.Cron = cron
+			c.setSlot(cron.Next(now))
+			return
+		}
+		log.Printf("[%s] %v, falling back to interval %s", hc.Name, err, hc.period())
+	}
+
+	if hc.Interval <= 0 && hc.IntervalMs <= 0 && hc.Type != CheckTypeHeartbeat {
+		log.Printf("[%s] No interval set, running every %s", hc.Name, hc.period())
+	}
+	c.setSlot(now.Add(time.Duration(rand.Int63n(int64(hc.period())))))
+}
+
+// advance moves the check to its next slot after a run at now. Slots that
+// were missed entirely are skipped rather than run back to back.
+func (c *ScheduledCheck) advance(now time.Time) {
+	if c.Cron != nil {
+		next := c.Cron.Next(c.slot)
+		if next.Before(now) {
+			next = c.Cron.Next(now)
+		}
+		c.setSlot(next)
+		return
+	}
+
+	period := c.HealthCheck.period()
+	next := c.slot.Add(period)
+	if !next.After(now) {
+		next = now.Add(period)
+	}
+	c.setSlot(next)
+}
+
+func (c *ScheduledCheck) setSlot(slot time.Time) {
+	c.slot = slot
+	c.NextRun = slot
+	if slot.IsZero() {
+		return
+	}
+	if c.HealthCheck.Jitter > 0 {
+		c.NextRun = slot.Add(time.Duration(rand.Int63n(int64(c.HealthCheck.Jitter))) * time.Millisecond)
+	}
+}
+
+func (c *ScheduledCheck) describeSchedule() string {
+	switch {
+	case c.NextRun.IsZero():
+		return "not scheduled"
+	case c.Cron != nil:
+		return fmt.Sprintf("schedule: %s %s, next run %s", c.Cron.Expression, c.Cron.Location, c.NextRun.Format(time.RFC3339))
+	default:
+		return fmt.Sprintf("interval: %s", c.HealthCheck.period())
+	}
+}
+
+// scheduleQueue is a min-heap of checks ordered by their next run
+type scheduleQueue []*ScheduledCheck
+
+func (q scheduleQueue) Len() int           { return len(q) }
+func (q scheduleQueue) Less(i, j int) bool { return q[i].NextRun.Before(q[j].NextRun) }
+
+func (q scheduleQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *scheduleQueue) Push(x interface{}) {
+	check := x.(*ScheduledCheck)
+	check.index = len(*q)
+	*q = append(*q, check)
+}
+
+func (q *scheduleQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	check := old[n-1]
+	old[n-1] = nil
+	check.index = -1
+	*q = old[:n-1]
+	return check
+}
+
+// schedule (re)positions a check in the queue. Callers hold m.mu.
+func (m *HealthCheckManager) schedule(check *ScheduledCheck) {
+	switch {
+	case check.NextRun.IsZero():
+		m.unschedule(check)
+		return
+	case check.index >= 0:
+		heap.Fix(&m.queue, check.index)
+	default:
+		heap.Push(&m.queue, check)
+	}
+	m.wakeScheduler()
+}
+
+// unschedule removes a check from the queue. Callers hold m.mu.
+func (m *HealthCheckManager) unschedule(check *ScheduledCheck) {
+	if check.index >= 0 {
+		heap.Remove(&m.queue, check.index)
+	}
+}
+
+func (m *HealthCheckManager) wakeScheduler() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runScheduler sleeps until the earliest next run, starts every check that
+// is due and goes back to sleep. Configuration changes wake it early.
+func (m *HealthCheckManager) runScheduler(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-m.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+
+		timer.Reset(m.runDue(time.Now()))
+	}
+}
+
+// runDue dispatches the checks whose next run has passed and returns how
+// long to wait for the next one.
+func (m *HealthCheckManager) runDue(now time.Time) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.queue) > 0 && !m.queue[0].NextRun.After(now) {
+		check := m.queue[0]
+
+		m.dispatch(check, now)
+
+		check.advance(now)
+		if check.NextRun.IsZero() {
+			heap.Pop(&m.queue)
+		} else {
+			heap.Fix(&m.queue, 0)
+		}
+	}
+
+	if len(m.queue) == 0 {
+		return idleSchedulerWait
+	}
+	return m.queue[0].NextRun.Sub(now)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+)
+
+var schedulerTestNow = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestHealthCheckPeriod(t *testing.T) {
+	tests := []struct {
+		hc   HealthCheck
+		want time.Duration
+	}{
+		{HealthCheck{Interval: 30}, 30 * time.Second},
+		{HealthCheck{Interval: 30, IntervalMs: 500}, 500 * time.Millisecond},
+		{HealthCheck{IntervalMs: 10}, minCheckInterval},
+		{HealthCheck{}, minCheckInterval},
+		{HealthCheck{Interval: -5}, minCheckInterval},
+	}
+
+	for _, test := range tests {
+		if got := test.hc.period(); got != test.want {
+			t.Errorf("period(%+v) = %v, want %v", test.hc, got, test.want)
+		}
+	}
+}
+
+func TestScheduleQueueOrder(t *testing.T) {
+	m := &HealthCheckManager{wake: make(chan struct{}, 1)}
+
+	checks := make(map[string]*ScheduledCheck)
+	for i, name := range []string{"a", "b", "c", "d"} {
+		checks[name] = &ScheduledCheck{
+			HealthCheck: HealthCheck{Name: name},
+			NextRun:     schedulerTestNow.Add(time.Duration(i+1) * time.Second),
+			index:       -1,
+		}
+	}
+	for _, name := range []string{"c", "a", "d", "b"} {
+		m.schedule(checks[name])
+	}
+
+	// moving a check to the back and unscheduling another reorders the heap
+	checks["a"].NextRun = schedulerTestNow.Add(time.Minute)
+	m.schedule(checks["a"])
+	m.unschedule(checks["c"])
+	checks["d"].NextRun = time.Time{}
+	m.schedule(checks["d"])
+
+	var order []string
+	for m.queue.Len() > 0 {
+		order = append(order, heap.Pop(&m.queue).(*ScheduledCheck).HealthCheck.Name)
+	}
+	if len(order) != 2 || order[0] != "b" || order[1] != "a" {
+		t.Errorf("order = %v", order)
+	}
+	if checks["c"].index != -1 || checks["d"].index != -1 {
+		t.Errorf("removed checks keep an index: %d, %d", checks["c"].index, checks["d"].index)
+	}
+	select {
+	case <-m.wake:
+	default:
+		t.Error("scheduling did not wake the scheduler")
+	}
+}
+
+func TestScheduledCheckJitter(t *testing.T) {
+	hc := HealthCheck{Name: "api", Interval: 60, Jitter: 500}
+
+	for i := 0; i < 50; i++ {
+		check := newScheduledCheck(hc, schedulerTestNow)
+		if check.slot.Before(schedulerTestNow) || !check.slot.Before(schedulerTestNow.Add(time.Minute)) {
+			t.Fatalf("first slot %v is not within one period", check.slot)
+		}
+
+		slot := check.slot
+		check.advance(slot)
+		if !check.slot.Equal(slot.Add(time.Minute)) {
+			t.Fatalf("slot drifted to %v", check.slot)
+		}
+		if jitter := check.NextRun.Sub(check.slot); jitter < 0 || jitter >= 500*time.Millisecond {
+			t.Fatalf("jitter = %v", jitter)
+		}
+	}
+}
+
+func TestScheduledCheckAdvance(t *testing.T) {
+	tests := []struct {
+		name string
+		hc   HealthCheck
+		slot time.Time
+		now  time.Time
+		want time.Time
+	}{
+		{"next interval", HealthCheck{Interval: 60}, schedulerTestNow, schedulerTestNow.Add(time.Second), schedulerTestNow.Add(time.Minute)},
+		{"missed intervals are skipped", HealthCheck{Interval: 60}, schedulerTestNow, schedulerTestNow.Add(5 * time.Minute), schedulerTestNow.Add(6 * time.Minute)},
+		{"next cron slot", HealthCheck{Schedule: "*/5 * * * *"}, schedulerTestNow, schedulerTestNow.Add(time.Second), schedulerTestNow.Add(5 * time.Minute)},
+		{"missed cron slots are skipped", HealthCheck{Schedule: "*/5 * * * *"}, schedulerTestNow, schedulerTestNow.Add(12 * time.Minute), schedulerTestNow.Add(15 * time.Minute)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := newScheduledCheck(test.hc, test.slot.Add(-time.Second))
+			check.setSlot(test.slot)
+			check.advance(test.now)
+			if !check.NextRun.Equal(test.want) {
+				t.Errorf("next run = %v, want %v", check.NextRun, test.want)
+			}
+		})
+	}
+}
+
+func TestScheduledCheckConfigure(t *testing.T) {
+	tests := []struct {
+		name     string
+		hc       HealthCheck
+		cron     bool
+		schedule string
+	}{
+		{"cron", HealthCheck{Schedule: "0 9 * * *", Timezone: "Europe/Berlin", Interval: 60}, true, "schedule: 0 9 * * * Europe/Berlin, next run 2024-03-02T09:00:00+01:00"},
+		{"broken cron falls back to the interval", HealthCheck{Schedule: "0 9 * *", Interval: 60}, false, "interval: 1m0s"},
+		{"no interval", HealthCheck{}, false, "interval: 100ms"},
+		{"cron that never matches", HealthCheck{Schedule: "0 0 30 2 *"}, true, "not scheduled"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := newScheduledCheck(test.hc, schedulerTestNow)
+			if (check.Cron != nil) != test.cron {
+				t.Errorf("cron = %v", check.Cron)
+			}
+			if got := check.describeSchedule(); got != test.schedule {
+				t.Errorf("describeSchedule() = %q", got)
+			}
+		})
+	}
+}
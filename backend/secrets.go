@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	secretsCollection = "secrets"
+	secretCacheTTL    = time.Minute
+
+	// Shorter values are not masked, they would mangle unrelated text
+	minMaskedLength = 4
+
+	// ${env:...} only reads variables with this prefix or on the allowlist,
+	// so a check can't send the backend's own configuration to its target
+	envPlaceholderPrefix = "HST_"
+)
+
+var (
+	placeholderPattern = regexp.MustCompile(`\$\{(secret|env):([A-Za-z0-9_.-]+)\}`)
+	secretNamePattern  = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+	// Never resolved, even when allowlisted
+	deniedEnvPlaceholders = map[string]bool{
+		"SECRETS_MASTER_KEY": true,
+		"MONGO_URI":          true,
+		"SMTP_PASSWORD":      true,
+	}
+)
+
+// Secret is stored encrypted with AES-GCM; the name is bound as additional
+// data so a ciphertext can't be moved to another name.
+type Secret struct {
+	Name       string    `bson:"name" json:"name"`
+	Nonce      []byte    `bson:"nonce" json:"-"`
+	Ciphertext []byte    `bson:"ciphertext" json:"-"`
+	CreatedAt  time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+type cachedSecret struct {
+	value   string
+	fetched time.Time
+}
+
+type SecretStore struct {
+	mongoHelper *MongoHelper
+	aead        cipher.AEAD // nil without a master key
+	allowedEnv  map[string]bool
+
+	mu    sync.Mutex
+	cache map[string]cachedSecret
+}
+
+// NewSecretStore takes the base64 encoded AES key (16, 24 or 32 bytes) and
+// the variables ${env:...} may read besides HST_*. Without a key, ${env:...}
+// placeholders still work but secrets don't.
+func NewSecretStore(db *mongo.Database, masterKey string, envAllowlist []string) (*SecretStore, error) {
+	s := &SecretStore{
+		mongoHelper: NewMongoHelper(db),
+		allowedEnv:  make(map[string]bool),
+		cache:       make(map[string]cachedSecret),
+	}
+
+	for _, name := range envAllowlist {
+		if name = strings.TrimSpace(name); name != "" {
+			s.allowedEnv[name] = true
+		}
+	}
+
+	if masterKey == "" {
+		return s, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding master key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	s.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *SecretStore) Put(ctx context.Context, name, value string) error {
+	if s.aead == nil {
+		return fmt.Errorf("SECRETS_MASTER_KEY is not set")
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	now := time.Now()
+	_, err := s.mongoHelper.GetCollection(secretsCollection).UpdateOne(ctx,
+		bson.M{"name": name},
+		bson.M{
+			"$set": bson.M{
+				"nonce":      nonce,
+				"ciphertext": s.aead.Seal(nil, nonce, []byte(value), []byte(name)),
+				"updatedAt":  now,
+			},
+			"$setOnInsert": bson.M{"createdAt": now},
+		},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error saving secret: %w", err)
+	}
+
+	s.forget(name)
+	return nil
+}
+
+func (s *SecretStore) Get(ctx context.Context, name string) (string, error) {
+	if s.aead == nil {
+		return "", fmt.Errorf("secret '%s' can't be read: SECRETS_MASTER_KEY is not set", name)
+	}
+
+	s.mu.Lock()
+	cached, ok := s.cache[name]
+	s.mu.Unlock()
+	if ok && time.Since(cached.fetched) < secretCacheTTL {
+		return cached.value, nil
+	}
+
+	var secret Secret
+	err := s.mongoHelper.GetCollection(secretsCollection).FindOne(ctx, bson.M{"name": name}).Decode(&secret)
+	if err == mongo.ErrNoDocuments {
+		return "", fmt.Errorf("secret '%s' not found", name)
+	} else if err != nil {
+		return "", fmt.Errorf("error loading secret '%s': %w", name, err)
+	}
+
+	plaintext, err := s.aead.Open(nil, secret.Nonce, secret.Ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting secret '%s': %w", name, err)
+	}
+
+	s.mu.Lock()
+	s.cache[name] = cachedSecret{value: string(plaintext), fetched: time.Now()}
+	s.mu.Unlock()
+
+	return string(plaintext), nil
+}
+
+func (s *SecretStore) Delete(ctx context.Context, name string) (bool, error) {
+	result, err := s.mongoHelper.GetCollection(secretsCollection).DeleteOne(ctx, bson.M{"name": name})
+	if err != nil {
+		return false, fmt.Errorf("error deleting secret: %w", err)
+	}
+
+	s.forget(name)
+	return result.DeletedCount > 0, nil
+}
+
+func (s *SecretStore) forget(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, name)
+}
+
+func (s *SecretStore) envAllowed(name string) bool {
+	if deniedEnvPlaceholders[name] {
+		return false
+	}
+	return strings.HasPrefix(name, envPlaceholderPrefix) || s.allowedEnv[name]
+}
+
+// Placeholders resolves ${secret:name} and ${env:VAR} for one execution and
+// remembers the substituted values so they can be masked again in results.
+type Placeholders struct {
+	ctx    context.Context
+	store  *SecretStore
+	err    error
+	values map[string]string // resolved value -> placeholder
+}
+
+func (s *SecretStore) Placeholders(ctx context.Context) *Placeholders {
+	return &Placeholders{ctx: ctx, store: s}
+}
+
+// Resolve returns s with every placeholder replaced; the first failure is
+// kept in Err and s is returned unchanged.
+func (p *Placeholders) Resolve(s string) string {
+	if p.err != nil || !strings.Contains(s, "${") {
+		return s
+	}
+
+	resolved := placeholderPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+		if p.err != nil {
+			return placeholder
+		}
+
+		match := placeholderPattern.FindStringSubmatch(placeholder)
+		var value string
+		switch match[1] {
+		case "env":
+			if !p.store.envAllowed(match[2]) {
+				p.err = fmt.Errorf("environment variable '%s' can't be used in placeholders, only %s* and PLACEHOLDER_ENV_ALLOWLIST are", match[2], envPlaceholderPrefix)
+				return placeholder
+			}
+			var ok bool
+			if value, ok = os.LookupEnv(match[2]); !ok {
+				p.err = fmt.Errorf("environment variable '%s' is not set", match[2])
+				return placeholder
+			}
+		case "secret":
+			var err error
+			if value, err = p.store.Get(p.ctx, match[2]); err != nil {
+				p.err = err
+				return placeholder
+			}
+		}
+
+		if p.values == nil {
+			p.values = make(map[string]string)
+		}
+		p.values[value] = placeholder
+		return value
+	})
+
+	if p.err != nil {
+		return s
+	}
+	return resolved
+}
+
+func (p *Placeholders) ResolveHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	resolved := make(map[string]string, len(headers))
+	for key, value := range headers {
+		resolved[key] = p.Resolve(value)
+	}
+	return resolved
+}
+
+func (p *Placeholders) ResolveAuth(auth *AuthConfig) *AuthConfig {
+	if auth == nil {
+		return nil
+	}
+	resolved := *auth
+	resolved.Username = p.Resolve(auth.Username)
+	resolved.Password = p.Resolve(auth.Password)
+	resolved.Token = p.Resolve(auth.Token)
+	resolved.TokenURL = p.Resolve(auth.TokenURL)
+	resolved.ClientID = p.Resolve(auth.ClientID)
+	resolved.ClientSecret = p.Resolve(auth.ClientSecret)
+	return &resolved
+}
+
+// ResolveCheck returns a copy of the check with the url, headers, body and
+// auth of the probe and its steps resolved.
+func (p *Placeholders) ResolveCheck(hc HealthCheck) HealthCheck {
+	hc.URL = p.Resolve(hc.URL)
+	hc.Headers = p.ResolveHeaders(hc.Headers)
+	hc.Body = p.Resolve(hc.Body)
+	hc.Auth = p.ResolveAuth(hc.Auth)
+
+	if len(hc.Steps) > 0 {
+		steps := make([]HealthCheckStep, len(hc.Steps))
+		for i, step := range hc.Steps {
+			step.URL = p.Resolve(step.URL)
+			step.Headers = p.ResolveHeaders(step.Headers)
+			step.Body = p.Resolve(step.Body)
+			steps[i] = step
+		}
+		hc.Steps = steps
+	}
+
+	return hc
+}
+
+func (p *Placeholders) ResolveRequest(req LoadTestRequest) LoadTestRequest {
+	req.URL = p.Resolve(req.URL)
+	req.Headers = p.ResolveHeaders(req.Headers)
+	req.Body = p.Resolve(req.Body)
+	req.Auth = p.ResolveAuth(req.Auth)
+	return req
+}
+
+func (p *Placeholders) Err() error {
+	return p.err
+}
+
+// Mask puts the placeholders back wherever a resolved value shows up, e.g.
+// a url echoed in a transport error.
+func (p *Placeholders) Mask(s string) string {
+	if len(p.values) == 0 || s == "" {
+		return s
+	}
+
+	values := make([]string, 0, len(p.values))
+	for value := range p.values {
+		if len(value) >= minMaskedLength {
+			values = append(values, value)
+		}
+	}
+	// Longest first so a value containing another is masked whole
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, p.values[value])
+	}
+	return s
+}
+
+func (p *Placeholders) maskString(s *string) {
+	if s != nil {
+		*s = p.Mask(*s)
+	}
+}
+
+func (p *Placeholders) maskAssertions(results []AssertionResult) {
+	for i := range results {
+		results[i].Expected = p.Mask(results[i].Expected)
+		results[i].Actual = p.Mask(results[i].Actual)
+		results[i].Message = p.Mask(results[i].Message)
+	}
+}
+
+// MaskLog masks everything in a probe result that can echo the request
+func (p *Placeholders) MaskLog(logEntry *HealthCheckLog) {
+	if len(p.values) == 0 {
+		return
+	}
+
+	p.maskString(logEntry.Error)
+	p.maskAssertions(logEntry.Assertions)
+	for i := range logEntry.Warnings {
+		logEntry.Warnings[i] = p.Mask(logEntry.Warnings[i])
+	}
+	for i := range logEntry.Steps {
+		step := &logEntry.Steps[i]
+		step.URL = p.Mask(step.URL)
+		p.maskString(step.Error)
+		p.maskAssertions(step.Assertions)
+	}
+}
+
+type secretRequest struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HandleSecrets lists secret names (GET) and creates or replaces a secret
+// (POST); values are never returned.
+func (s *SecretStore) HandleSecrets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cursor, err := s.mongoHelper.GetCollection(secretsCollection).Find(r.Context(), bson.M{},
+			options.Find().SetSort(bson.M{"name": 1}).SetProjection(bson.M{"nonce": 0, "ciphertext": 0}))
+		if err != nil {
+			JSONError(w, "Error loading secrets", http.StatusInternalServerError)
+			return
+		}
+		defer cursor.Close(r.Context())
+
+		secrets := []Secret{}
+		if err := cursor.All(r.Context(), &secrets); err != nil {
+			JSONError(w, "Error decoding secrets", http.StatusInternalServerError)
+			return
+		}
+
+		JSONResponse(w, secrets, http.StatusOK)
+
+	case http.MethodPost:
+		var req secretRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			JSONError(w, fmt.Sprintf("Error decoding JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if !secretNamePattern.MatchString(req.Name) {
+			JSONError(w, "name may only contain letters, digits and _ . -", http.StatusBadRequest)
+			return
+		}
+
+		if err := s.Put(r.Context(), req.Name, req.Value); err != nil {
+			JSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		JSONResponse(w, map[string]string{"name": req.Name, "placeholder": "${secret:" + req.Name + "}"}, http.StatusCreated)
+
+	default:
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleSecret deletes a secret (DELETE)
+func (s *SecretStore) HandleSecret(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	deleted, err := s.Delete(r.Context(), r.PathValue("name"))
+	if err != nil {
+		JSONError(w, "Error deleting secret", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		JSONError(w, "Secret not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvPlaceholders(t *testing.T) {
+	t.Setenv("HST_API_TOKEN", "tok-123456")
+	t.Setenv("UPSTREAM_HOST", "upstream.internal")
+	t.Setenv("SECRETS_MASTER_KEY", "c2VjcmV0c2VjcmV0c2VjcmV0")
+	t.Setenv("MONGO_URI", "mongodb://admin:pw@mongo:27017")
+	t.Setenv("HOME", "/root")
+
+	store, err := NewSecretStore(newTestDatabase(t), "", []string{"UPSTREAM_HOST", " MONGO_URI "})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{input: "Bearer ${env:HST_API_TOKEN}", want: "Bearer tok-123456"},
+		{input: "https://${env:UPSTREAM_HOST}/health", want: "https://upstream.internal/health"},
+		{input: "https://attacker/?k=${env:SECRETS_MASTER_KEY}", wantErr: "'SECRETS_MASTER_KEY' can't be used"},
+		{input: "${env:MONGO_URI}", wantErr: "'MONGO_URI' can't be used"},
+		{input: "${env:HOME}", wantErr: "'HOME' can't be used"},
+		{input: "${env:HST_MISSING}", wantErr: "'HST_MISSING' is not set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			placeholders := store.Placeholders(context.Background())
+			got := placeholders.Resolve(tt.input)
+
+			if tt.wantErr != "" {
+				if placeholders.Err() == nil || !strings.Contains(placeholders.Err().Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", placeholders.Err(), tt.wantErr)
+				}
+				if got != tt.input {
+					t.Errorf("got %q, want the input unchanged", got)
+				}
+				return
+			}
+			if placeholders.Err() != nil || got != tt.want {
+				t.Fatalf("got %q, %v", got, placeholders.Err())
+			}
+		})
+	}
+}
+
+func TestDisallowedEnvPlaceholderFailsRunAsConfigError(t *testing.T) {
+	t.Setenv("MONGO_URI", "mongodb://admin:pw@mongo:27017")
+
+	store, err := NewSecretStore(newTestDatabase(t), "", nil)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	m := NewHealthCheckManager(newTestDatabase(t), newTestDispatcher(t), store, 1)
+
+	hc := HealthCheck{Name: "leak", URL: "http://127.0.0.1:1/?k=${env:MONGO_URI}", Method: "GET"}
+	logEntry := m.probe(context.Background(), hc)
+
+	if logEntry.Success || logEntry.ErrorClass != ErrorClassConfig {
+		t.Fatalf("log = %+v", logEntry)
+	}
+	if strings.Contains(*logEntry.Error, "admin:pw") {
+		t.Errorf("error leaks the value: %s", *logEntry.Error)
+	}
+}
+
+func TestSecretPlaceholdersAreMasked(t *testing.T) {
+	store, err := NewSecretStore(newTestDatabase(t), "MDEyMzQ1Njc4OWFiY2RlZg==", nil)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	store.cache["db-password"] = cachedSecret{value: "hunter2hunter2", fetched: time.Now()}
+	store.cache["pin"] = cachedSecret{value: "42", fetched: time.Now()}
+
+	placeholders := store.Placeholders(context.Background())
+	hc := placeholders.ResolveCheck(HealthCheck{
+		URL:     "https://db.internal/health?pin=${secret:pin}",
+		Headers: map[string]string{"X-Password": "${secret:db-password}"},
+	})
+	if placeholders.Err() != nil || hc.Headers["X-Password"] != "hunter2hunter2" || hc.URL != "https://db.internal/health?pin=42" {
+		t.Fatalf("resolved %+v, %v", hc, placeholders.Err())
+	}
+
+	message := "login failed for hunter2hunter2 with pin 42"
+	logEntry := HealthCheckLog{
+		Error:    &message,
+		Warnings: []string{"password hunter2hunter2 expires soon"},
+		Steps:    []StepResult{{URL: "https://db.internal/hunter2hunter2"}},
+	}
+	placeholders.MaskLog(&logEntry)
+
+	// Values shorter than minMaskedLength are left alone
+	if *logEntry.Error != "login failed for ${secret:db-password} with pin 42" {
+		t.Errorf("error = %q", *logEntry.Error)
+	}
+	if logEntry.Warnings[0] != "password ${secret:db-password} expires soon" || logEntry.Steps[0].URL != "https://db.internal/${secret:db-password}" {
+		t.Errorf("log = %+v", logEntry)
+	}
+}
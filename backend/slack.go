@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const ChannelSlack = "slack"
+
+type SlackNotifier struct {
+	client *http.Client
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Title  string       `json:"title"`
+	Text   string       `json:"text,omitempty"`
+	Fields []slackField `json:"fields,omitempty"`
+	Ts     int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func NewSlackNotifier(client *http.Client) *SlackNotifier {
+	return &SlackNotifier{client: client}
+}
+
+func (n *SlackNotifier) Type() string {
+	return ChannelSlack
+}
+
+func (n *SlackNotifier) Send(ctx context.Context, channel NotificationChannel, notification Notification) error {
+	if channel.URL == "" {
+		return fmt.Errorf("slack webhook url is required")
+	}
+
+	fields := []slackField{
+		{Title: "State", Value: fmt.Sprintf("%s → %s", notification.From, notification.To), Short: true},
+	}
+	if notification.URL != "" {
+		fields = append(fields, slackField{Title: "URL", Value: notification.URL, Short: true})
+	}
+	if notification.Detail != "" {
+		fields = append(fields, slackField{Title: "Detail", Value: notification.Detail})
+	}
+
+	message := slackMessage{
+		Text: notification.Summary(),
+		Attachments: []slackAttachment{
+			{
+				Color:  slackColor(notification),
+				Title:  notification.CheckName,
+				Text:   notification.Reason,
+				Fields: fields,
+				Ts:     notification.Timestamp.Unix(),
+			},
+		},
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("error encoding slack payload: %w", err)
+	}
+
+	return postJSON(ctx, n.client, channel.URL, payload, channel.Headers)
+}
+
+func slackColor(notification Notification) string {
+	switch {
+	case !notification.IsProblem():
+		return "good"
+	case notification.IsCritical():
+		return "danger"
+	default:
+		return "warning"
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// captureJSON records the path and decoded body of every request
+type captureJSON struct {
+	paths   []string
+	headers []http.Header
+	bodies  []map[string]interface{}
+}
+
+func (c *captureJSON) server(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		c.paths = append(c.paths, r.URL.RequestURI())
+		c.headers = append(c.headers, r.Header.Clone())
+		c.bodies = append(c.bodies, body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSlackPayload(t *testing.T) {
+	tests := []struct {
+		to    string
+		color string
+	}{
+		{StateDown, "danger"},
+		{StateDegraded, "warning"},
+		{StateUp, "good"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.to, func(t *testing.T) {
+			capture := &captureJSON{}
+			server := capture.server(t)
+
+			notification := testNotification(tt.to)
+			notification.Detail = "status 503"
+			channel := NotificationChannel{Type: ChannelSlack, URL: server.URL + "/services/T/B/X"}
+
+			if err := NewSlackNotifier(server.Client()).Send(context.Background(), channel, notification); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			if len(capture.bodies) != 1 || capture.paths[0] != "/services/T/B/X" {
+				t.Fatalf("requests = %v", capture.paths)
+			}
+
+			body := capture.bodies[0]
+			if body["text"] != notification.Summary() {
+				t.Errorf("text = %v", body["text"])
+			}
+			attachment := body["attachments"].([]interface{})[0].(map[string]interface{})
+			if attachment["color"] != tt.color || attachment["title"] != "api" || attachment["text"] != notification.Reason {
+				t.Errorf("attachment = %v", attachment)
+			}
+			if attachment["ts"] != float64(notification.Timestamp.Unix()) {
+				t.Errorf("ts = %v", attachment["ts"])
+			}
+			fields := attachment["fields"].([]interface{})
+			if len(fields) != 3 {
+				t.Fatalf("fields = %v", fields)
+			}
+			state := fields[0].(map[string]interface{})
+			if state["value"] != notification.From+" → "+tt.to {
+				t.Errorf("state field = %v", state)
+			}
+		})
+	}
+}
+
+func TestSlackRequiresURL(t *testing.T) {
+	err := NewSlackNotifier(http.DefaultClient).Send(context.Background(), NotificationChannel{Type: ChannelSlack}, testNotification(StateDown))
+	if err == nil {
+		t.Fatal("expected an error without url")
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	StateUnknown  = "UNKNOWN"
+	StateUp       = "UP"
+	StateDegraded = "DEGRADED"
+	StateDown     = "DOWN"
+	StateFlapping = "FLAPPING"
+)
+
+const (
+	defaultFailureThreshold  = 3
+	defaultRecoveryThreshold = 2
+	defaultFlapThreshold     = 5
+	defaultFlapWindow        = 600 // seconds
+
+	stateTransitionsCollection = "healthcheck_state_transitions"
+)
+
+type StateTransition struct {
+	CheckID              primitive.ObjectID `bson:"checkId"`
+	CheckName            string             `bson:"checkName"`
+	From                 string             `bson:"from"`
+	To                   string             `bson:"to"`
+	Timestamp            time.Time          `bson:"timestamp"`
+	Reason               string             `bson:"reason"`
+	Detail               string             `bson:"detail,omitempty"`
+	ConsecutiveFailures  int                `bson:"consecutiveFailures"`
+	ConsecutiveSuccesses int                `bson:"consecutiveSuccesses"`
+	PreviousDuration     int64              `bson:"previousDuration,omitempty"` // seconds spent in the previous state
+
+	// What Restore needs to carry flap detection across a restart
+	BaseState     string      `bson:"baseState,omitempty"`
+	RecentChanges []time.Time `bson:"recentChanges,omitempty"` // base state changes within the flap window
+}
+
+type HealthCheckState struct {
+	State                string
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	LastChange           time.Time
+
+	// baseState ignores flapping; changes holds when baseState moved inside the flap window
+	baseState string
+	changes   []time.Time
+	restored  bool
+}
+
+func NewHealthCheckState() *HealthCheckState {
+	return &HealthCheckState{
+		State:     StateUnknown,
+		baseState: StateUnknown,
+	}
+}
+
+func (hc HealthCheck) failureThreshold() int {
+	if hc.FailureThreshold > 0 {
+		return hc.FailureThreshold
+	}
+	return defaultFailureThreshold
+}
+
+func (hc HealthCheck) recoveryThreshold() int {
+	if hc.RecoveryThreshold > 0 {
+		return hc.RecoveryThreshold
+	}
+	return defaultRecoveryThreshold
+}
+
+func (hc HealthCheck) flapThreshold() int {
+	if hc.FlapThreshold > 0 {
+		return hc.FlapThreshold
+	}
+	return defaultFlapThreshold
+}
+
+func (hc HealthCheck) flapWindow() time.Duration {
+	if hc.FlapWindow > 0 {
+		return time.Duration(hc.FlapWindow) * time.Second
+	}
+	return defaultFlapWindow * time.Second
+}
+
+// Observe feeds a probe result into the state machine and returns the
+// resulting transition, or nil if the visible state did not change.
+func (s *HealthCheckState) Observe(hc HealthCheck, logEntry HealthCheckLog, now time.Time) *StateTransition {
+	var reason string
+
+	if logEntry.Success {
+		s.ConsecutiveSuccesses++
+		s.ConsecutiveFailures = 0
+	} else {
+		s.ConsecutiveFailures++
+		s.ConsecutiveSuccesses = 0
+	}
+
+	base := s.baseState
+	switch {
+	case !logEntry.Success && s.ConsecutiveFailures >= hc.failureThreshold():
+		base = StateDown
+		reason = fmt.Sprintf("%d consecutive failures", s.ConsecutiveFailures)
+	case !logEntry.Success:
+		if base != StateDown {
+			base = StateDegraded
+			reason = fmt.Sprintf("%d of %d failures before down", s.ConsecutiveFailures, hc.failureThreshold())
+		}
+	case len(logEntry.Warnings) > 0 && (base == StateUp || base == StateUnknown || s.ConsecutiveSuccesses >= hc.recoveryThreshold()):
+		base = StateDegraded
+		reason = strings.Join(logEntry.Warnings, "; ")
+	case base == StateUnknown || s.ConsecutiveSuccesses >= hc.recoveryThreshold():
+		base = StateUp
+		reason = fmt.Sprintf("%d consecutive successes", s.ConsecutiveSuccesses)
+	}
+
+	if base != s.baseState {
+		s.baseState = base
+		s.changes = append(s.changes, now)
+	}
+
+	cutoff := now.Add(-hc.flapWindow())
+	kept := s.changes[:0]
+	for _, changedAt := range s.changes {
+		if changedAt.After(cutoff) {
+			kept = append(kept, changedAt)
+		}
+	}
+	s.changes = kept
+
+	next := base
+	if len(s.changes) >= hc.flapThreshold() {
+		next = StateFlapping
+		reason = fmt.Sprintf("%d state changes within %s", len(s.changes), hc.flapWindow())
+	}
+
+	if next == s.State {
+		return nil
+	}
+
+	transition := &StateTransition{
+		CheckID:              hc.ID,
+		CheckName:            hc.Name,
+		From:                 s.State,
+		To:                   next,
+		Timestamp:            now,
+		Reason:               reason,
+		ConsecutiveFailures:  s.ConsecutiveFailures,
+		ConsecutiveSuccesses: s.ConsecutiveSuccesses,
+		BaseState:            base,
+		RecentChanges:        append([]time.Time(nil), s.changes...),
+	}
+	if !logEntry.Success {
+		transition.Detail = describeFailure(logEntry)
+	} else if len(logEntry.Warnings) > 0 {
+		transition.Detail = strings.Join(logEntry.Warnings, "; ")
+	}
+	if !s.LastChange.IsZero() {
+		transition.PreviousDuration = int64(now.Sub(s.LastChange).Seconds())
+	}
+
+	s.State = next
+	s.LastChange = now
+
+	return transition
+}
+
+// Restore seeds the state from the last persisted transition so a restart
+// does not report every check as a fresh UNKNOWN -> UP transition.
+func (s *HealthCheckState) Restore(transition *StateTransition) {
+	s.restored = true
+	if transition == nil {
+		return
+	}
+
+	s.State = transition.To
+	s.LastChange = transition.Timestamp
+	s.baseState = transition.BaseState
+	s.changes = append([]time.Time(nil), transition.RecentChanges...)
+
+	// Transitions stored before the base state was recorded
+	if s.baseState == "" {
+		s.baseState = transition.To
+		if transition.To == StateFlapping {
+			s.baseState = StateDegraded
+		}
+	}
+}
+
+func describeFailure(logEntry HealthCheckLog) string {
+	if logEntry.Error != nil {
+		return *logEntry.Error
+	}
+	return DescribeFailedAssertions(logEntry.Assertions)
+}
+
+func (m *HealthCheckManager) updateState(ctx context.Context, hc HealthCheck, logEntry HealthCheckLog) {
+	id := hc.ID.Hex()
+
+	m.mu.RLock()
+	check, exists := m.checks[id]
+	restored := exists && check.State.restored
+	m.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	var last *StateTransition
+	if !restored {
+		var err error
+		last, err = m.findLastTransition(ctx, hc.ID)
+		if err != nil {
+			log.Printf("Failed to restore state for %s: %v", hc.Name, err)
+		}
+	}
+
+	m.mu.Lock()
+	check, exists = m.checks[id]
+	if !exists {
+		m.mu.Unlock()
+		return
+	}
+	if !check.State.restored {
+		check.State.Restore(last)
+	}
+	transition := check.State.Observe(hc, logEntry, logEntry.Timestamp)
+	m.mu.Unlock()
+
+	if transition == nil {
+		return
+	}
+
+	log.Printf("[%s] State changed: %s -> %s (%s)", hc.Name, transition.From, transition.To, transition.Reason)
+
+	if err := m.mongoHelper.InsertLog(ctx, stateTransitionsCollection, transition); err != nil {
+		log.Printf("Failed to save state transition for %s: %v", hc.Name, err)
+	}
+
+	// The first result after startup is not news
+	if transition.From == StateUnknown && transition.To == StateUp {
+		return
+	}
+
+	m.notifier.Notify(ctx, hc.Notifications, newNotificationFromTransition(hc, transition))
+}
+
+func (m *HealthCheckManager) findLastTransition(ctx context.Context, checkID primitive.ObjectID) (*StateTransition, error) {
+	collection := m.mongoHelper.GetCollection(stateTransitionsCollection)
+
+	opts := options.FindOne().SetSort(bson.M{"timestamp": -1})
+
+	var transition StateTransition
+	err := collection.FindOne(ctx, bson.M{"checkId": checkID}, opts).Decode(&transition)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error finding last transition: %w", err)
+	}
+
+	return &transition, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var stateTestStart = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestHealthCheckStateThresholds(t *testing.T) {
+	hc := HealthCheck{Name: "api", FailureThreshold: 2, RecoveryThreshold: 2}
+
+	steps := []struct {
+		success bool
+		want    string
+		changed bool
+	}{
+		{true, StateUp, true},
+		{false, StateDegraded, true},
+		{false, StateDown, true},
+		{false, StateDown, false},
+		{true, StateDown, false},
+		{true, StateUp, true},
+		{true, StateUp, false},
+	}
+
+	state := NewHealthCheckState()
+	for i, step := range steps {
+		transition := state.Observe(hc, HealthCheckLog{Success: step.success}, stateTestStart.Add(time.Duration(i)*time.Minute))
+		if state.State != step.want || (transition != nil) != step.changed {
+			t.Fatalf("step %d: state = %s, transition = %+v", i, state.State, transition)
+		}
+	}
+}
+
+func TestHealthCheckStateTransition(t *testing.T) {
+	hc := HealthCheck{Name: "api", FailureThreshold: 1}
+	message := "connection refused"
+
+	state := NewHealthCheckState()
+	state.Observe(hc, HealthCheckLog{Success: true}, stateTestStart)
+	transition := state.Observe(hc, HealthCheckLog{Error: &message}, stateTestStart.Add(90*time.Second))
+
+	if transition == nil || transition.From != StateUp || transition.To != StateDown {
+		t.Fatalf("transition = %+v", transition)
+	}
+	if transition.Reason != "1 consecutive failures" || transition.Detail != message {
+		t.Errorf("reason = %q, detail = %q", transition.Reason, transition.Detail)
+	}
+	if transition.PreviousDuration != 90 || transition.ConsecutiveFailures != 1 {
+		t.Errorf("transition = %+v", transition)
+	}
+}
+
+func TestHealthCheckStateFlapping(t *testing.T) {
+	hc := HealthCheck{Name: "api", FailureThreshold: 1, RecoveryThreshold: 1, FlapThreshold: 3, FlapWindow: 60}
+
+	steps := []struct {
+		at      time.Duration
+		success bool
+		want    string
+	}{
+		{0, true, StateUp},
+		{10 * time.Second, false, StateDown},
+		{20 * time.Second, true, StateFlapping},
+		{30 * time.Second, false, StateFlapping},
+		// all four changes are still inside the window
+		{50 * time.Second, false, StateFlapping},
+		{75 * time.Second, false, StateDown},
+	}
+
+	state := NewHealthCheckState()
+	for i, step := range steps {
+		state.Observe(hc, HealthCheckLog{Success: step.success}, stateTestStart.Add(step.at))
+		if state.State != step.want {
+			t.Fatalf("step %d: state = %s, want %s", i, state.State, step.want)
+		}
+	}
+}
+
+func TestHealthCheckStateRestoreKeepsFlapping(t *testing.T) {
+	hc := HealthCheck{Name: "api", FailureThreshold: 1, RecoveryThreshold: 1, FlapThreshold: 3, FlapWindow: 60}
+
+	state := NewHealthCheckState()
+	var last *StateTransition
+	for i, success := range []bool{true, false, true} {
+		if transition := state.Observe(hc, HealthCheckLog{Success: success}, stateTestStart.Add(time.Duration(i)*10*time.Second)); transition != nil {
+			last = transition
+		}
+	}
+	if last == nil || last.To != StateFlapping {
+		t.Fatalf("last transition = %+v", last)
+	}
+
+	// After a restart the next probe still sees three changes in the window
+	restarted := NewHealthCheckState()
+	restarted.Restore(last)
+	if transition := restarted.Observe(hc, HealthCheckLog{Success: true}, stateTestStart.Add(40*time.Second)); transition != nil {
+		t.Errorf("transition after restart = %+v", transition)
+	}
+}
+
+func TestHealthCheckStateRestore(t *testing.T) {
+	hc := HealthCheck{Name: "api", FlapThreshold: 3, FlapWindow: 60}
+
+	// The base state moved three times in the last minute before the restart
+	flapping := &StateTransition{
+		To:        StateFlapping,
+		BaseState: StateDown,
+		RecentChanges: []time.Time{
+			stateTestStart.Add(-50 * time.Second),
+			stateTestStart.Add(-30 * time.Second),
+			stateTestStart.Add(-10 * time.Second),
+		},
+	}
+
+	tests := []struct {
+		name    string
+		last    *StateTransition
+		success bool
+		want    string
+		changed bool
+	}{
+		{"nothing persisted", nil, true, StateUp, true},
+		{"up", &StateTransition{To: StateUp}, true, StateUp, false},
+		{"down until recovered", &StateTransition{To: StateDown}, true, StateDown, false},
+		{"down stays down", &StateTransition{To: StateDown}, false, StateDown, false},
+		{"degraded below the failure threshold", &StateTransition{To: StateDegraded}, false, StateDegraded, false},
+		{"flapping within the window", flapping, true, StateFlapping, false},
+		{"flapping without recent changes", &StateTransition{To: StateFlapping, BaseState: StateDown}, false, StateDown, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := NewHealthCheckState()
+			state.Restore(test.last)
+			transition := state.Observe(hc, HealthCheckLog{Success: test.success}, stateTestStart)
+			if state.State != test.want || (transition != nil) != test.changed {
+				t.Errorf("state = %s, transition = %+v", state.State, transition)
+			}
+		})
+	}
+}
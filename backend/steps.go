@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const headerExtractPrefix = "header:"
+
+var templateVariable = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+type HealthCheckStep struct {
+	Name        string            `bson:"name"`
+	URL         string            `bson:"url"`
+	Method      string            `bson:"method,omitempty"`
+	Headers     map[string]string `bson:"headers,omitempty"`
+	Body        string            `bson:"body,omitempty"`
+	ContentType string            `bson:"contentType,omitempty"`
+	StatusCode  int               `bson:"statusCode,omitempty"` // defaults to any 2xx
+	Assertions  []Assertion       `bson:"assertions,omitempty"`
+	Extract     map[string]string `bson:"extract,omitempty"` // variable -> JSONPath, or "header:<Name>"
+}
+
+type StepResult struct {
+	Name         string            `bson:"name"`
+	URL          string            `bson:"url"` // unrendered, extracted values never reach the log
+	Method       string            `bson:"method"`
+	StatusCode   int               `bson:"statusCode"`
+	ResponseTime int64             `bson:"responseTime"`
+	Success      bool              `bson:"success"`
+	Error        *string           `bson:"error,omitempty"`
+	ErrorClass   string            `bson:"errorClass,omitempty"`
+	Assertions   []AssertionResult `bson:"assertions,omitempty"`
+	Extracted    []string          `bson:"extracted,omitempty"` // variable names only
+	Timings      *RequestTimings   `bson:"timings,omitempty"`
+}
+
+func (step HealthCheckStep) effectiveAssertions() []Assertion {
+	for _, assertion := range step.Assertions {
+		if assertion.Type == AssertionStatusCode {
+			return step.Assertions
+		}
+	}
+
+	status := Assertion{Type: AssertionStatusCode, Operator: OperatorIn, Value: "2xx"}
+	if step.StatusCode != 0 {
+		status = Assertion{Type: AssertionStatusCode, Operator: OperatorEquals, Value: fmt.Sprint(step.StatusCode)}
+	}
+
+	return append([]Assertion{status}, step.Assertions...)
+}
+
+// probeSteps runs the steps in order, threading extracted variables into
+// later steps, and stops at the first step that fails.
+func (m *HealthCheckManager) probeSteps(ctx context.Context, hc HealthCheck) HealthCheckLog {
+	start := time.Now()
+
+	client, _, err := m.httpClient(hc)
+	if err != nil {
+		return newFailedLog(start, 0, err)
+	}
+
+	variables := builtinVariables(hc, start)
+	logEntry := HealthCheckLog{Success: true}
+
+	for i, step := range hc.Steps {
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step-%d", i+1)
+		}
+
+		result := m.runStep(ctx, client, hc.Auth, step, variables)
+		result.Name = name
+
+		logEntry.Steps = append(logEntry.Steps, result)
+		logEntry.StatusCode = result.StatusCode
+
+		if !result.Success {
+			detail := DescribeFailedAssertions(result.Assertions)
+			if result.Error != nil {
+				detail = *result.Error
+			}
+			errMsg := fmt.Sprintf("step '%s' failed: %s", name, detail)
+
+			logEntry.Success = false
+			logEntry.Error = &errMsg
+			logEntry.ErrorClass = result.ErrorClass
+			break
+		}
+	}
+
+	logEntry.Timestamp = time.Now()
+	logEntry.ResponseTime = time.Since(start).Milliseconds()
+
+	return logEntry
+}
+
+func (m *HealthCheckManager) runStep(ctx context.Context, client *http.Client, auth *AuthConfig, step HealthCheckStep, variables map[string]string) StepResult {
+	start := time.Now()
+
+	method := step.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	result := StepResult{
+		URL:    step.URL,
+		Method: method,
+	}
+
+	fail := func(err error, class string) StepResult {
+		errMsg := err.Error()
+		result.Error = &errMsg
+		result.ErrorClass = class
+		result.ResponseTime = time.Since(start).Milliseconds()
+		return result
+	}
+
+	var missing []string
+	render := func(s string) string {
+		rendered, undefined := RenderTemplate(s, variables)
+		missing = append(missing, undefined...)
+		return rendered
+	}
+
+	headers := make(map[string]string, len(step.Headers))
+	for key, value := range step.Headers {
+		headers[key] = render(value)
+	}
+	req := probeRequest{
+		Method:      method,
+		URL:         render(step.URL),
+		Headers:     headers,
+		Body:        render(step.Body),
+		ContentType: step.ContentType,
+		Auth:        auth,
+	}
+	if len(missing) > 0 {
+		return fail(fmt.Errorf("undefined variables: %s", strings.Join(missing, ", ")), ErrorClassOther)
+	}
+
+	resp, err := m.sendRequest(ctx, client, req)
+	result.StatusCode = resp.StatusCode
+	result.Timings = resp.Timings
+	if err != nil {
+		return fail(err, classifyError(err))
+	}
+
+	result.ResponseTime = time.Since(start).Milliseconds()
+	result.Assertions = EvaluateAssertions(step.effectiveAssertions(), resp)
+	result.Success = AssertionsPassed(result.Assertions)
+	if !result.Success {
+		result.ErrorClass = failureClass(HealthCheckLog{Assertions: result.Assertions})
+		return result
+	}
+
+	if len(step.Extract) > 0 {
+		if err := extractVariables(step.Extract, resp, variables); err != nil {
+			result.Success = false
+			return fail(err, ErrorClassAssertion)
+		}
+		for variable := range step.Extract {
+			result.Extracted = append(result.Extracted, variable)
+		}
+	}
+
+	return result
+}
+
+func extractVariables(extract map[string]string, resp ResponseSnapshot, variables map[string]string) error {
+	var (
+		document interface{}
+		decoded  bool
+	)
+
+	for variable, source := range extract {
+		if strings.HasPrefix(source, headerExtractPrefix) {
+			header := strings.TrimSpace(strings.TrimPrefix(source, headerExtractPrefix))
+			value := resp.Header.Get(header)
+			if value == "" {
+				return fmt.Errorf("cannot extract '%s': header '%s' not present", variable, header)
+			}
+			variables[variable] = value
+			continue
+		}
+
+		if !decoded {
+			var err error
+			if document, err = decodeJSONBody(resp.Body); err != nil {
+				return fmt.Errorf("cannot extract '%s': body is not valid JSON: %v", variable, err)
+			}
+			decoded = true
+		}
+
+		value, found, err := LookupJSONPath(document, source)
+		if err != nil {
+			return fmt.Errorf("cannot extract '%s': %v", variable, err)
+		}
+		if !found {
+			return fmt.Errorf("cannot extract '%s': path '%s' not found", variable, source)
+		}
+		variables[variable] = FormatJSONValue(value)
+	}
+
+	return nil
+}
+
+// builtinVariables are available to templated bodies and to every step.
+func builtinVariables(hc HealthCheck, now time.Time) map[string]string {
+	return map[string]string{
+		"checkName": hc.Name,
+		"timestamp": now.UTC().Format(time.RFC3339),
+		"unix":      strconv.FormatInt(now.Unix(), 10),
+		"unixMs":    strconv.FormatInt(now.UnixMilli(), 10),
+		"uuid":      newUUID(),
+		"randomInt": strconv.Itoa(mathrand.Intn(1000000)),
+	}
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// RenderTemplate replaces {{name}} placeholders and reports the names that
+// have no value; unknown placeholders are left untouched.
+func RenderTemplate(s string, variables map[string]string) (string, []string) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	var missing []string
+	rendered := templateVariable.ReplaceAllStringFunc(s, func(match string) string {
+		name := templateVariable.FindStringSubmatch(match)[1]
+		if value, ok := variables[name]; ok {
+			return value
+		}
+		missing = append(missing, name)
+		return match
+	})
+
+	return rendered, missing
+}
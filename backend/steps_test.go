@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	variables := map[string]string{"token": "t0k3n", "order.id": "42"}
+
+	tests := []struct {
+		template string
+		want     string
+		missing  []string
+	}{
+		{"plain", "plain", nil},
+		{"Bearer {{token}}", "Bearer t0k3n", nil},
+		{"/orders/{{ order.id }}/items/{{order.id}}", "/orders/42/items/42", nil},
+		{"{{token}}:{{secret}}", "t0k3n:{{secret}}", []string{"secret"}},
+		{"{{ not a variable }}", "{{ not a variable }}", nil},
+	}
+
+	for _, test := range tests {
+		rendered, missing := RenderTemplate(test.template, variables)
+		if rendered != test.want || !reflect.DeepEqual(missing, test.missing) {
+			t.Errorf("RenderTemplate(%q) = %q, %v", test.template, rendered, missing)
+		}
+	}
+}
+
+func TestExtractVariables(t *testing.T) {
+	resp := ResponseSnapshot{
+		Header: http.Header{"Location": {"/orders/42"}},
+		Body:   []byte(`{"token":"t0k3n","order":{"id":42,"lines":[{"sku":"A-1"}]}}`),
+	}
+
+	tests := []struct {
+		name    string
+		extract map[string]string
+		want    map[string]string
+		err     string
+	}{
+		{"json paths", map[string]string{"token": "$.token", "id": "$.order.id", "sku": "order.lines[0].sku"}, map[string]string{"token": "t0k3n", "id": "42", "sku": "A-1"}, ""},
+		{"header", map[string]string{"next": "header: Location"}, map[string]string{"next": "/orders/42"}, ""},
+		{"missing header", map[string]string{"etag": "header:ETag"}, nil, "cannot extract 'etag': header 'ETag' not present"},
+		{"missing path", map[string]string{"user": "$.user.id"}, nil, "cannot extract 'user': path '$.user.id' not found"},
+		{"invalid path", map[string]string{"bad": "$.order["}, nil, "cannot extract 'bad': invalid JSONPath '$.order[': unclosed bracket"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			variables := make(map[string]string)
+			err := extractVariables(test.extract, resp, variables)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("err = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(variables, test.want) {
+				t.Errorf("variables = %v, err = %v", variables, err)
+			}
+		})
+	}
+}
+
+func TestStepEffectiveAssertions(t *testing.T) {
+	tests := []struct {
+		step HealthCheckStep
+		want Assertion
+	}{
+		{HealthCheckStep{}, Assertion{Type: AssertionStatusCode, Operator: OperatorIn, Value: "2xx"}},
+		{HealthCheckStep{StatusCode: 201}, Assertion{Type: AssertionStatusCode, Operator: OperatorEquals, Value: "201"}},
+		{HealthCheckStep{Assertions: []Assertion{{Type: AssertionStatusCode, Value: "3xx"}}}, Assertion{Type: AssertionStatusCode, Value: "3xx"}},
+	}
+
+	for _, test := range tests {
+		if got := test.step.effectiveAssertions(); got[0] != test.want {
+			t.Errorf("effectiveAssertions(%+v) = %+v", test.step, got)
+		}
+	}
+}
+
+func TestProbeStepsThreadsVariables(t *testing.T) {
+	var ordersAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			fmt.Fprint(w, `{"token":"t0k3n"}`)
+		case "/orders":
+			ordersAuth = r.Header.Get("Authorization")
+			w.Header().Set("Location", "/orders/42")
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	hc := HealthCheck{Name: "checkout", Steps: []HealthCheckStep{
+		{Name: "login", URL: server.URL + "/login", Method: http.MethodPost, Extract: map[string]string{"token": "$.token"}},
+		{URL: server.URL + "/orders", Method: http.MethodPost, Headers: map[string]string{"Authorization": "Bearer {{token}}"}, StatusCode: 201, Extract: map[string]string{"order": "header:Location"}},
+		{Name: "order", URL: server.URL + "{{order}}"},
+		{Name: "never runs", URL: server.URL + "/login"},
+	}}
+
+	m := &HealthCheckManager{client: server.Client()}
+	logEntry := m.probeSteps(context.Background(), hc)
+
+	if ordersAuth != "Bearer t0k3n" {
+		t.Errorf("extracted token was not sent: %q", ordersAuth)
+	}
+	if logEntry.Success || len(logEntry.Steps) != 3 || logEntry.StatusCode != http.StatusNotFound {
+		t.Fatalf("log = %+v", logEntry)
+	}
+	if logEntry.Steps[1].Name != "step-2" || !logEntry.Steps[1].Success || logEntry.Steps[1].Extracted[0] != "order" {
+		t.Errorf("second step = %+v", logEntry.Steps[1])
+	}
+	if logEntry.Steps[2].URL != server.URL+"{{order}}" {
+		t.Errorf("stored url was rendered: %q", logEntry.Steps[2].URL)
+	}
+	if *logEntry.Error != "step 'order' failed: statusCode: status code 404 does not satisfy in 2xx" || logEntry.ErrorClass != ErrorClassStatus {
+		t.Errorf("error = %q, class %q", *logEntry.Error, logEntry.ErrorClass)
+	}
+}
+
+func TestProbeStepsUndefinedVariable(t *testing.T) {
+	hc := HealthCheck{Name: "checkout", Steps: []HealthCheckStep{
+		{Name: "order", URL: "http://127.0.0.1:1/orders/{{order}}"},
+	}}
+
+	logEntry := (&HealthCheckManager{client: http.DefaultClient}).probeSteps(context.Background(), hc)
+
+	if logEntry.Success || logEntry.Steps[0].StatusCode != 0 || *logEntry.Error != "step 'order' failed: undefined variables: order" {
+		t.Fatalf("log = %+v", logEntry)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTCPReadTimeout = 5 * time.Second
+	maxBannerSize         = 4096
+	maxStoredBannerSize   = 512
+)
+
+type TCPCheckConfig struct {
+	Payload      string `bson:"payload,omitempty"`      // sent right after connecting
+	ExpectBanner string `bson:"expectBanner,omitempty"` // regex the server greeting or reply must match
+	ReadTimeout  int    `bson:"readTimeout,omitempty"`  // ms
+}
+
+func (m *HealthCheckManager) probeTCP(ctx context.Context, hc HealthCheck) HealthCheckLog {
+	start := time.Now()
+
+	config := TCPCheckConfig{}
+	if hc.TCP != nil {
+		config = *hc.TCP
+	}
+
+	// Without a pattern the banner is read until the peer stops sending
+	var pattern *regexp.Regexp
+	if config.ExpectBanner != "" {
+		var err error
+		pattern, err = regexp.Compile(config.ExpectBanner)
+		if err != nil {
+			return newConfigErrorLog(start, fmt.Errorf("invalid tcp.expectBanner: %w", err))
+		}
+	}
+
+	address, err := targetAddress(hc.URL)
+	if err != nil {
+		return newFailedLog(start, 0, err)
+	}
+
+	dialer := &net.Dialer{Timeout: hc.timeout()}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return newFailedLog(start, 0, err)
+	}
+	defer conn.Close()
+
+	connectTime := time.Since(start).Milliseconds()
+
+	readTimeout := defaultTCPReadTimeout
+	if config.ReadTimeout > 0 {
+		readTimeout = time.Duration(config.ReadTimeout) * time.Millisecond
+	}
+
+	if config.Payload != "" {
+		conn.SetWriteDeadline(time.Now().Add(readTimeout))
+		if _, err := conn.Write([]byte(config.Payload)); err != nil {
+			logEntry := newFailedLog(start, 0, fmt.Errorf("error sending payload: %w", err))
+			logEntry.ConnectTime = connectTime
+			return logEntry
+		}
+	}
+
+	logEntry := HealthCheckLog{
+		ConnectTime: connectTime,
+		Success:     true,
+	}
+
+	assertions := hc.Assertions
+	if config.ExpectBanner != "" {
+		assertions = append([]Assertion{{Type: AssertionRegex, Value: config.ExpectBanner}}, assertions...)
+	}
+
+	if len(assertions) > 0 {
+		banner, err := readBanner(conn, pattern, time.Now().Add(readTimeout))
+		if err != nil && len(banner) == 0 {
+			logEntry = newFailedLog(start, 0, fmt.Errorf("no banner received: %w", err))
+			logEntry.ConnectTime = connectTime
+			return logEntry
+		}
+
+		logEntry.Banner = truncate(string(banner), maxStoredBannerSize)
+		logEntry.Assertions = EvaluateAssertions(assertions, ResponseSnapshot{Body: banner})
+		logEntry.Success = AssertionsPassed(logEntry.Assertions)
+	}
+
+	logEntry.Timestamp = time.Now()
+	logEntry.ResponseTime = time.Since(start).Milliseconds()
+
+	return logEntry
+}
+
+// readBanner reads until the pattern matches, the peer closes the
+// connection, the buffer is full or the deadline passes.
+func readBanner(conn net.Conn, pattern *regexp.Regexp, deadline time.Time) ([]byte, error) {
+	conn.SetReadDeadline(deadline)
+
+	buffer := make([]byte, 0, maxBannerSize)
+	chunk := make([]byte, 1024)
+
+	for len(buffer) < maxBannerSize {
+		n, err := conn.Read(chunk)
+		buffer = append(buffer, chunk[:n]...)
+
+		if pattern != nil && pattern.Match(buffer) {
+			return buffer, nil
+		}
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) && len(buffer) > 0 {
+				return buffer, nil
+			}
+			return buffer, err
+		}
+	}
+
+	return buffer, nil
+}
+
+// targetAddress accepts "scheme://host:port" as well as a bare "host:port"
+func targetAddress(target string) (string, error) {
+	address := target
+	if strings.Contains(target, "://") {
+		parsed, err := url.Parse(target)
+		if err != nil {
+			return "", fmt.Errorf("invalid target '%s': %w", target, err)
+		}
+		address = parsed.Host
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return "", fmt.Errorf("invalid target '%s': %w", target, err)
+	}
+
+	return address, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveBanner writes the greeting in parts, so a reader that stops after
+// the first read only sees the start of it
+func serveBanner(t *testing.T, parts ...string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for _, part := range parts {
+					conn.Write([]byte(part))
+					time.Sleep(50 * time.Millisecond)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestTCPInvalidBannerPatternIsConfigError(t *testing.T) {
+	address := serveBanner(t, "220 ready\r\n")
+	hc := HealthCheck{Type: CheckTypeTCP, URL: address, TCP: &TCPCheckConfig{ExpectBanner: "220 (ready"}}
+
+	logEntry := (&HealthCheckManager{}).probeTCP(context.Background(), hc)
+
+	if logEntry.Success || logEntry.ErrorClass != ErrorClassConfig || logEntry.Error == nil {
+		t.Fatalf("log = %+v", logEntry)
+	}
+}
+
+func TestTCPBannerWithoutPatternIsReadCompletely(t *testing.T) {
+	address := serveBanner(t, "220 mail.example.com ", "ESMTP ready\r\n")
+	hc := HealthCheck{
+		Type:       CheckTypeTCP,
+		URL:        address,
+		TCP:        &TCPCheckConfig{ReadTimeout: 500},
+		Assertions: []Assertion{{Type: AssertionRegex, Value: "ESMTP ready"}},
+	}
+
+	logEntry := (&HealthCheckManager{}).probeTCP(context.Background(), hc)
+
+	if !logEntry.Success || logEntry.Banner != "220 mail.example.com ESMTP ready\r\n" {
+		t.Fatalf("log = %+v, banner %q", logEntry, logEntry.Banner)
+	}
+}
+
+func TestTCPBannerPatternStopsReading(t *testing.T) {
+	address := serveBanner(t, "220 ready\r\n", "more\r\n")
+	hc := HealthCheck{Type: CheckTypeTCP, URL: address, TCP: &TCPCheckConfig{ExpectBanner: `^220 `, ReadTimeout: 2000}}
+
+	start := time.Now()
+	logEntry := (&HealthCheckManager{}).probeTCP(context.Background(), hc)
+
+	if !logEntry.Success || logEntry.Banner != "220 ready\r\n" {
+		t.Fatalf("log = %+v, banner %q", logEntry, logEntry.Banner)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("read did not stop at the match")
+	}
+}
+
+func TestTCPConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	logEntry := (&HealthCheckManager{}).probeTCP(context.Background(), HealthCheck{Type: CheckTypeTCP, URL: "tcp://" + address})
+
+	if logEntry.Success || logEntry.Error == nil || logEntry.ErrorClass != ErrorClassConnection {
+		t.Fatalf("log = %+v", logEntry)
+	}
+}
+
+func TestTargetAddress(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+		valid  bool
+	}{
+		{"tcp://db.internal:5432", "db.internal:5432", true},
+		{"db.internal:5432", "db.internal:5432", true},
+		{"[::1]:6379", "[::1]:6379", true},
+		{"db.internal", "", false},
+		{"tcp://db.internal", "", false},
+	}
+
+	for _, test := range tests {
+		address, err := targetAddress(test.target)
+		if address != test.want || (err == nil) != test.valid {
+			t.Errorf("targetAddress(%q) = %q, %v", test.target, address, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RequestTimings splits a request into phases, in fractional milliseconds.
+// Phases that did not happen (DNS for an IP literal, everything up to TTFB
+// on a reused connection) are zero.
+type RequestTimings struct {
+	DNS        float64 `bson:"dns" json:"dns"`
+	Connect    float64 `bson:"connect" json:"connect"`
+	TLS        float64 `bson:"tls" json:"tls"`
+	TTFB       float64 `bson:"ttfb" json:"ttfb"`         // request written to first response byte
+	Transfer   float64 `bson:"transfer" json:"transfer"` // first byte to end of body
+	Total      float64 `bson:"total" json:"total"`
+	ConnReused bool    `bson:"connReused" json:"connReused"`
+}
+
+type PhaseStats struct {
+	Average float64 `bson:"average" json:"average"`
+	P95     float64 `bson:"p95" json:"p95"`
+	Max     float64 `bson:"max" json:"max"`
+}
+
+// TimingsSummary aggregates the phases of every request of a load test;
+// averages include the zero phases of reused connections.
+type TimingsSummary struct {
+	DNS               PhaseStats `bson:"dns" json:"dns"`
+	Connect           PhaseStats `bson:"connect" json:"connect"`
+	TLS               PhaseStats `bson:"tls" json:"tls"`
+	TTFB              PhaseStats `bson:"ttfb" json:"ttfb"`
+	Transfer          PhaseStats `bson:"transfer" json:"transfer"`
+	ReusedConnections int        `bson:"reusedConnections" json:"reusedConnections"`
+}
+
+// requestTracer records httptrace events. Dialing may race several
+// addresses, so callbacks can arrive concurrently.
+type requestTracer struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func newRequestTracer(ctx context.Context) (*requestTracer, context.Context) {
+	t := &requestTracer{start: time.Now()}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart, false) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone, true) },
+		ConnectStart: func(network, addr string) {
+			t.mark(&t.connectStart, false)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				t.mark(&t.connectDone, true)
+			}
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart, false) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mark(&t.tlsDone, true)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { t.mark(&t.wroteRequest, true) },
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte, false)
+		},
+	}
+
+	return t, httptrace.WithClientTrace(ctx, trace)
+}
+
+// mark keeps the first start and the last end of a phase
+func (t *requestTracer) mark(field *time.Time, last bool) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if field.IsZero() || last {
+		*field = now
+	}
+}
+
+// Timings is called once the body has been read (or the request failed)
+func (t *requestTracer) Timings(end time.Time) RequestTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timings := RequestTimings{
+		DNS:        phaseMs(t.dnsStart, t.dnsDone),
+		Connect:    phaseMs(t.connectStart, t.connectDone),
+		TLS:        phaseMs(t.tlsStart, t.tlsDone),
+		TTFB:       phaseMs(t.wroteRequest, t.firstByte),
+		Transfer:   phaseMs(t.firstByte, end),
+		Total:      phaseMs(t.start, end),
+		ConnReused: t.reused,
+	}
+
+	return timings
+}
+
+func phaseMs(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return float64(end.Sub(start).Microseconds()) / 1000
+}
+
+func summarizeTimings(timings []RequestTimings) *TimingsSummary {
+	if len(timings) == 0 {
+		return nil
+	}
+
+	phases := make([][]float64, 5)
+	summary := &TimingsSummary{}
+
+	for _, t := range timings {
+		for i, value := range []float64{t.DNS, t.Connect, t.TLS, t.TTFB, t.Transfer} {
+			phases[i] = append(phases[i], value)
+		}
+		if t.ConnReused {
+			summary.ReusedConnections++
+		}
+	}
+
+	for i, stats := range []*PhaseStats{&summary.DNS, &summary.Connect, &summary.TLS, &summary.TTFB, &summary.Transfer} {
+		*stats = newPhaseStats(phases[i])
+	}
+
+	return summary
+}
+
+func newPhaseStats(values []float64) PhaseStats {
+	sort.Float64s(values)
+
+	var total float64
+	for _, value := range values {
+		total += value
+	}
+
+	return PhaseStats{
+		Average: total / float64(len(values)),
+		P95:     calculatePercentile(values, 95),
+		Max:     values[len(values)-1],
+	}
+}
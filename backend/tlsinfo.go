@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+type TLSInfo struct {
+	Version         string    `bson:"version"`
+	Subject         string    `bson:"subject"`
+	Issuer          string    `bson:"issuer"`
+	SANs            []string  `bson:"sans,omitempty"`
+	SerialNumber    string    `bson:"serialNumber"`
+	NotBefore       time.Time `bson:"notBefore"`
+	NotAfter        time.Time `bson:"notAfter"`
+	DaysUntilExpiry int       `bson:"daysUntilExpiry"`
+	ChainValid      bool      `bson:"chainValid"`
+	ChainError      string    `bson:"chainError,omitempty"`
+}
+
+// InspectTLS describes the leaf certificate of a connection. When the
+// handshake skipped verification the chain is verified here against roots
+// (nil means the system pool) so the log still reports chain validity.
+func InspectTLS(state *tls.ConnectionState, serverName string, roots *x509.CertPool, now time.Time) *TLSInfo {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	leaf := state.PeerCertificates[0]
+
+	info := &TLSInfo{
+		Version:         tls.VersionName(state.Version),
+		Subject:         leaf.Subject.String(),
+		Issuer:          leaf.Issuer.String(),
+		SerialNumber:    leaf.SerialNumber.String(),
+		NotBefore:       leaf.NotBefore,
+		NotAfter:        leaf.NotAfter,
+		DaysUntilExpiry: int(leaf.NotAfter.Sub(now).Hours() / 24),
+	}
+
+	info.SANs = append(info.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	if len(state.VerifiedChains) > 0 {
+		info.ChainValid = true
+		return info
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if state.ServerName != "" {
+		serverName = state.ServerName
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		info.ChainError = err.Error()
+	} else {
+		info.ChainValid = true
+	}
+
+	return info
+}
+
+// applyTLSRules turns certificate findings into warnings (DEGRADED) or a
+// failure when the certificate is already expired.
+func applyTLSRules(hc HealthCheck, logEntry *HealthCheckLog, now time.Time) {
+	info := logEntry.TLS
+	if info == nil {
+		return
+	}
+
+	if now.After(info.NotAfter) {
+		errMsg := fmt.Sprintf("certificate expired on %s", info.NotAfter.Format(time.RFC3339))
+		logEntry.Success = false
+		logEntry.Error = &errMsg
+		logEntry.ErrorClass = ErrorClassTLS
+		return
+	}
+
+	if hc.TLSExpiryWarnDays > 0 && info.DaysUntilExpiry < hc.TLSExpiryWarnDays {
+		logEntry.Warnings = append(logEntry.Warnings, fmt.Sprintf("certificate expires in %d days (%s)",
+			info.DaysUntilExpiry, info.NotAfter.Format(time.RFC3339)))
+	}
+
+	if !info.ChainValid {
+		logEntry.Warnings = append(logEntry.Warnings, fmt.Sprintf("certificate chain invalid: %s", info.ChainError))
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCheckTimeout = 10 * time.Second
+
+	// Transports unused for this long are closed, e.g. after a check's
+	// client certificate was rotated; the cap bounds checks with many
+	// distinct settings.
+	transportIdleTTL       = 10 * time.Minute
+	transportSweepInterval = time.Minute
+	maxCachedTransports    = 100
+)
+
+// TransportOptions override the shared HTTP client for a single check
+type TransportOptions struct {
+	Timeout            int    `bson:"timeout,omitempty"`         // ms, 10s by default; also bounds tcp, dns and grpc checks
+	FollowRedirects    *bool  `bson:"followRedirects,omitempty"` // true by default
+	MaxRedirects       int    `bson:"maxRedirects,omitempty"`    // 10 by default
+	Proxy              string `bson:"proxy,omitempty"`           // http://, https:// or socks5:// url
+	InsecureSkipVerify bool   `bson:"insecureSkipVerify,omitempty"`
+	CACert             string `bson:"caCert,omitempty"`     // PEM bundle trusted instead of the system roots
+	ClientCert         string `bson:"clientCert,omitempty"` // PEM, presented for mTLS
+	ClientKey          string `bson:"clientKey,omitempty"`  // PEM
+}
+
+func (hc HealthCheck) timeout() time.Duration {
+	if hc.Transport != nil && hc.Transport.Timeout > 0 {
+		return time.Duration(hc.Transport.Timeout) * time.Millisecond
+	}
+	return defaultCheckTimeout
+}
+
+func (hc HealthCheck) httpClientConfig() HTTPClientConfig {
+	config := DefaultHTTPClientConfig()
+	config.Timeout = hc.timeout()
+
+	if options := hc.Transport; options != nil {
+		config.DisableRedirects = options.FollowRedirects != nil && !*options.FollowRedirects
+		config.MaxRedirects = options.MaxRedirects
+		config.ProxyURL = options.Proxy
+		config.InsecureSkipVerify = options.InsecureSkipVerify
+		config.CACertPEM = options.CACert
+		config.ClientCertPEM = options.ClientCert
+		config.ClientKeyPEM = options.ClientKey
+	}
+
+	return config
+}
+
+type cachedTransport struct {
+	transport *http.Transport
+	roots     *x509.CertPool
+	lastUsed  time.Time
+}
+
+// TransportCache shares one transport, and so one connection pool, between
+// all clients whose configs only differ in timeout and redirect policy.
+type TransportCache struct {
+	mu         sync.Mutex
+	transports map[string]*cachedTransport
+	lastSweep  time.Time
+}
+
+func NewTransportCache() *TransportCache {
+	return &TransportCache{
+		transports: make(map[string]*cachedTransport),
+	}
+}
+
+// Client returns a client for the config together with the CA pool it
+// trusts (nil for the system roots).
+func (c *TransportCache) Client(config HTTPClientConfig) (*http.Client, *x509.CertPool, error) {
+	key := transportKey(config)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= transportSweepInterval {
+		c.sweep(now)
+	}
+
+	cached, ok := c.transports[key]
+	if !ok {
+		transport, err := NewHTTPTransport(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		roots, err := config.RootCAs()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(c.transports) >= maxCachedTransports {
+			c.evictLeastRecentlyUsed()
+		}
+		cached = &cachedTransport{transport: transport, roots: roots}
+		c.transports[key] = cached
+	}
+	cached.lastUsed = now
+
+	client := &http.Client{
+		Timeout:       config.Timeout,
+		Transport:     cached.transport,
+		CheckRedirect: config.checkRedirect,
+	}
+
+	return client, cached.roots, nil
+}
+
+// sweep closes transports no check has used for transportIdleTTL; callers
+// hold c.mu. Requests still running on a closed transport finish normally.
+func (c *TransportCache) sweep(now time.Time) {
+	c.lastSweep = now
+	for key, cached := range c.transports {
+		if now.Sub(cached.lastUsed) > transportIdleTTL {
+			c.evict(key)
+		}
+	}
+}
+
+// evictLeastRecentlyUsed makes room for a new transport; callers hold c.mu
+func (c *TransportCache) evictLeastRecentlyUsed() {
+	var (
+		oldestKey string
+		oldest    time.Time
+	)
+	for key, cached := range c.transports {
+		if oldestKey == "" || cached.lastUsed.Before(oldest) {
+			oldestKey, oldest = key, cached.lastUsed
+		}
+	}
+	if oldestKey != "" {
+		c.evict(oldestKey)
+	}
+}
+
+func (c *TransportCache) evict(key string) {
+	c.transports[key].transport.CloseIdleConnections()
+	delete(c.transports, key)
+}
+
+// transportKey identifies the settings that live on the transport; PEM
+// material is hashed so the keys don't keep private keys around twice.
+func transportKey(config HTTPClientConfig) string {
+	tlsMaterial := sha256.Sum256([]byte(config.CACertPEM + "\x00" + config.ClientCertPEM + "\x00" + config.ClientKeyPEM))
+
+	return fmt.Sprintf("%d|%d|%d|%s|%s|%t|%x",
+		config.MaxIdleConns, config.MaxIdleConnsPerHost, config.MaxConnsPerHost, config.IdleConnTimeout,
+		config.ProxyURL, config.InsecureSkipVerify, tlsMaterial)
+}
+
+// httpClient returns the client for a check: the shared one unless the
+// check has transport options.
+func (m *HealthCheckManager) httpClient(hc HealthCheck) (*http.Client, *x509.CertPool, error) {
+	if hc.Transport == nil {
+		return m.client, nil, nil
+	}
+
+	client, roots, err := m.transports.Client(hc.httpClientConfig())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid transport options: %w", err)
+	}
+	return client, roots, nil
+}
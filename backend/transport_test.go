@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransportCacheSharesTransports(t *testing.T) {
+	cache := NewTransportCache()
+
+	config := DefaultHTTPClientConfig()
+	first, _, err := cache.Client(config)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+
+	config.Timeout = time.Second
+	config.DisableRedirects = true
+	second, _, _ := cache.Client(config)
+	if first.Transport != second.Transport {
+		t.Error("timeout and redirect policy should not need a new transport")
+	}
+
+	config.InsecureSkipVerify = true
+	third, _, _ := cache.Client(config)
+	if third.Transport == first.Transport || len(cache.transports) != 2 {
+		t.Errorf("got %d transports, want 2", len(cache.transports))
+	}
+}
+
+func TestTransportCacheEvictsIdleTransports(t *testing.T) {
+	cache := NewTransportCache()
+
+	old := DefaultHTTPClientConfig()
+	old.ProxyURL = "http://127.0.0.1:3128"
+	if _, _, err := cache.Client(old); err != nil {
+		t.Fatalf("client: %v", err)
+	}
+
+	// The check moved to new settings and nothing uses the old transport
+	for _, cached := range cache.transports {
+		cached.lastUsed = time.Now().Add(-transportIdleTTL - time.Minute)
+	}
+	cache.lastSweep = time.Time{}
+
+	current := DefaultHTTPClientConfig()
+	current.ProxyURL = "http://127.0.0.1:3129"
+	if _, _, err := cache.Client(current); err != nil {
+		t.Fatalf("client: %v", err)
+	}
+
+	if len(cache.transports) != 1 {
+		t.Fatalf("got %d transports, want 1", len(cache.transports))
+	}
+	if _, ok := cache.transports[transportKey(current)]; !ok {
+		t.Error("the transport in use was evicted")
+	}
+}
+
+func TestTransportCacheIsCapped(t *testing.T) {
+	cache := NewTransportCache()
+
+	config := DefaultHTTPClientConfig()
+	for i := 0; i < maxCachedTransports+10; i++ {
+		config.ProxyURL = fmt.Sprintf("http://127.0.0.1:%d", 10000+i)
+		if _, _, err := cache.Client(config); err != nil {
+			t.Fatalf("client: %v", err)
+		}
+	}
+
+	if len(cache.transports) != maxCachedTransports {
+		t.Errorf("got %d transports, want %d", len(cache.transports), maxCachedTransports)
+	}
+	if _, ok := cache.transports[transportKey(config)]; !ok {
+		t.Error("the newest transport was evicted")
+	}
+}
+
+func TestTransportCacheInvalidOptions(t *testing.T) {
+	tests := []struct {
+		options TransportOptions
+		err     string
+	}{
+		{TransportOptions{Proxy: "ftp://127.0.0.1:21"}, "unsupported proxy scheme 'ftp'"},
+		{TransportOptions{CACert: "not a certificate"}, "invalid CA bundle: no PEM certificates found"},
+		{TransportOptions{ClientCert: "not a certificate", ClientKey: "not a key"}, "invalid client certificate"},
+	}
+
+	m := &HealthCheckManager{transports: NewTransportCache()}
+	for _, test := range tests {
+		options := test.options
+		_, _, err := m.httpClient(HealthCheck{Transport: &options})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("options %+v: err = %v, want %q", test.options, err, test.err)
+		}
+	}
+}
+
+func TestTransportRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/again", http.StatusFound)
+	}))
+	defer server.Close()
+
+	follow := false
+	tests := []struct {
+		options TransportOptions
+		status  int
+		err     string
+	}{
+		{TransportOptions{FollowRedirects: &follow}, http.StatusFound, ""},
+		{TransportOptions{MaxRedirects: 2}, 0, "stopped after 2 redirects"},
+		{TransportOptions{}, 0, "stopped after 10 redirects"},
+	}
+
+	m := &HealthCheckManager{transports: NewTransportCache()}
+	for _, test := range tests {
+		options := test.options
+		client, _, err := m.httpClient(HealthCheck{Transport: &options})
+		if err != nil {
+			t.Fatalf("client: %v", err)
+		}
+		resp, err := client.Get(server.URL)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("options %+v: err = %v, want %q", test.options, err, test.err)
+			}
+			continue
+		}
+		if err != nil || resp.StatusCode != test.status {
+			t.Errorf("options %+v: resp = %v, err = %v", test.options, resp, err)
+			continue
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	tests := []struct {
+		transport *TransportOptions
+		want      time.Duration
+	}{
+		{nil, defaultCheckTimeout},
+		{&TransportOptions{}, defaultCheckTimeout},
+		{&TransportOptions{Timeout: 2500}, 2500 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		hc := HealthCheck{Transport: test.transport}
+		if got := hc.timeout(); got != test.want {
+			t.Errorf("timeout(%+v) = %v", test.transport, got)
+		}
+		if got := hc.httpClientConfig().Timeout; got != test.want {
+			t.Errorf("client timeout(%+v) = %v", test.transport, got)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	configPollInterval    = 30 * time.Second
+	changeStreamRetryWait = 5 * time.Second
+
+	// "The $changeStream stage is only supported on replica sets"
+	errCodeChangeStreamNotSupported = 40573
+)
+
+// Changing one of these moves the next run; other changes apply from the
+// next run on without disturbing the schedule.
+var scheduleFields = map[string]bool{
+	"type":       true,
+	"interval":   true,
+	"intervalMs": true,
+	"jitter":     true,
+	"schedule":   true,
+	"timezone":   true,
+}
+
+type healthCheckChange struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument *HealthCheck `bson:"fullDocument"`
+}
+
+// diffHealthChecks returns the bson names of every config field that differs
+func diffHealthChecks(old, new HealthCheck) []string {
+	var changed []string
+
+	oldValue := reflect.ValueOf(old)
+	newValue := reflect.ValueOf(new)
+	healthCheckType := oldValue.Type()
+
+	for i := 0; i < healthCheckType.NumField(); i++ {
+		field := healthCheckType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("bson"), ",")
+		if name == "" || name == "-" || name == "_id" || name == "updatedBy" || name == "updatedAt" {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+
+	return changed
+}
+
+// applyHealthCheck adds a check or applies a changed configuration; callers
+// hold m.mu
+func (m *HealthCheckManager) applyHealthCheck(hc HealthCheck, now time.Time) {
+	id := hc.ID.Hex()
+
+	check, exists := m.checks[id]
+	if !exists {
+		check = newScheduledCheck(hc, now)
+		m.checks[id] = check
+		m.schedule(check)
+		log.Printf("Loaded health check: %s (%s)", hc.Name, check.describeSchedule())
+		return
+	}
+
+	changed := diffHealthChecks(check.HealthCheck, hc)
+	if len(changed) == 0 {
+		return
+	}
+
+	log.Printf("Updating health check: %s (%s)", hc.Name, strings.Join(changed, ", "))
+
+	for _, field := range changed {
+		if scheduleFields[field] {
+			check.configure(hc, now)
+			m.schedule(check)
+			return
+		}
+	}
+	check.HealthCheck = hc
+}
+
+// removeHealthCheck stops scheduling a check; callers hold m.mu
+func (m *HealthCheckManager) removeHealthCheck(id, reason string) {
+	check, exists := m.checks[id]
+	if !exists {
+		return
+	}
+
+	log.Printf("Removing health check: %s (%s)", check.HealthCheck.Name, reason)
+	m.unschedule(check)
+	m.retireBurnRateAlerts(check)
+	delete(m.checks, id)
+}
+
+// reloadHealthChecks follows the healthchecks collection through a change
+// stream and falls back to polling where change streams don't exist
+// (standalone mongod). While the stream is down for any other reason, e.g.
+// missing changeStream privileges, the collection is polled between
+// reconnects so changes keep applying.
+func (m *HealthCheckManager) reloadHealthChecks(ctx context.Context) {
+	wait := changeStreamRetryWait
+	for {
+		started := time.Now()
+		err := m.watchHealthChecks(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && serverErr.HasErrorCode(errCodeChangeStreamNotSupported) {
+			log.Printf("Change streams not available, polling health checks every %s", configPollInterval)
+			m.pollHealthChecks(ctx)
+			return
+		}
+
+		// A stream that was up for a while reconnects quickly again
+		if time.Since(started) > configPollInterval {
+			wait = changeStreamRetryWait
+		}
+
+		log.Printf("Health check change stream stopped: %v, polling and reconnecting in %s", err, wait)
+		m.loadHealthChecks(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, configPollInterval)
+	}
+}
+
+// watchHealthChecks opens the stream before reading the collection, so
+// nothing changed in between is missed; replaying such a change is a no-op.
+func (m *HealthCheckManager) watchHealthChecks(ctx context.Context) error {
+	stream, err := m.mongoHelper.GetCollection("healthchecks").Watch(ctx, mongo.Pipeline{},
+		options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		return fmt.Errorf("error opening change stream: %w", err)
+	}
+	defer stream.Close(context.Background())
+
+	log.Println("Watching health checks for changes")
+	m.loadHealthChecks(ctx)
+
+	for stream.Next(ctx) {
+		var change healthCheckChange
+		if err := stream.Decode(&change); err != nil {
+			log.Printf("Failed to decode health check change: %v", err)
+			continue
+		}
+
+		switch change.OperationType {
+		case "insert", "update", "replace":
+			m.applyHealthCheckChange(ctx, change)
+		case "delete":
+			m.mu.Lock()
+			m.removeHealthCheck(change.DocumentKey.ID.Hex(), "deleted")
+			m.mu.Unlock()
+		case "drop", "rename", "dropDatabase", "invalidate":
+			return fmt.Errorf("change stream invalidated by %s", change.OperationType)
+		}
+	}
+
+	return stream.Err()
+}
+
+func (m *HealthCheckManager) applyHealthCheckChange(ctx context.Context, change healthCheckChange) {
+	hc := change.FullDocument
+
+	// Without a full document the check was deleted before the lookup
+	if hc == nil || hc.Status != "active" {
+		m.mu.Lock()
+		m.removeHealthCheck(change.DocumentKey.ID.Hex(), "deleted or inactive")
+		m.mu.Unlock()
+		return
+	}
+
+	m.ensureHeartbeatToken(ctx, hc)
+	m.recordRevision(ctx, hc)
+
+	m.mu.Lock()
+	m.applyHealthCheck(*hc, time.Now())
+	m.mu.Unlock()
+}
+
+func (m *HealthCheckManager) pollHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.loadHealthChecks(ctx)
+		}
+	}
+}
+
+// Maintenance windows are few and mostly changed through this API, which
+// reloads them right away; polling catches edits made elsewhere.
+func (m *HealthCheckManager) reloadMaintenanceWindows(ctx context.Context) {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.loadMaintenanceWindows(ctx)
+		}
+	}
+}
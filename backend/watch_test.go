@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDiffHealthChecks(t *testing.T) {
+	base := HealthCheck{
+		ID:       primitive.NewObjectID(),
+		Name:     "api",
+		URL:      "https://api.example.com",
+		Interval: 60,
+		Headers:  map[string]string{"Accept": "application/json"},
+	}
+
+	tests := []struct {
+		name   string
+		change func(hc *HealthCheck)
+		want   string
+	}{
+		{"unchanged", func(hc *HealthCheck) {}, ""},
+		{"id is ignored", func(hc *HealthCheck) { hc.ID = primitive.NewObjectID() }, ""},
+		{"url", func(hc *HealthCheck) { hc.URL = "https://api.example.com/v2" }, "url"},
+		{"headers", func(hc *HealthCheck) { hc.Headers = map[string]string{"Accept": "*/*"} }, "headers"},
+		{"interval and schedule", func(hc *HealthCheck) { hc.Interval = 0; hc.Schedule = "*/5 * * * *" }, "interval,schedule"},
+	}
+
+	for _, test := range tests {
+		hc := base
+		hc.Headers = map[string]string{"Accept": "application/json"}
+		test.change(&hc)
+		if got := strings.Join(diffHealthChecks(base, hc), ","); got != test.want {
+			t.Errorf("%s: changed = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestApplyHealthCheck(t *testing.T) {
+	m := newTestWorkerManager(10)
+	hc := HealthCheck{ID: primitive.NewObjectID(), Name: "api", URL: "https://api.example.com", Interval: 60}
+	id := hc.ID.Hex()
+
+	m.applyHealthCheck(hc, schedulerTestNow)
+	check := m.checks[id]
+	if check == nil || check.index < 0 {
+		t.Fatalf("check was not scheduled: %+v", check)
+	}
+	nextRun := check.NextRun
+
+	// Other changes apply from the next run on
+	hc.URL = "https://api.example.com/v2"
+	m.applyHealthCheck(hc, schedulerTestNow.Add(10*time.Second))
+	if m.checks[id] != check || check.HealthCheck.URL != hc.URL || !check.NextRun.Equal(nextRun) {
+		t.Errorf("url change: url = %s, next run = %v, want %v", check.HealthCheck.URL, check.NextRun, nextRun)
+	}
+
+	// Schedule changes move the next run
+	hc.Schedule = "0 * * * *"
+	m.applyHealthCheck(hc, schedulerTestNow.Add(10*time.Second))
+	if check.Cron == nil || !check.NextRun.Equal(schedulerTestNow.Add(time.Hour)) {
+		t.Errorf("schedule change: next run = %v", check.NextRun)
+	}
+
+	m.removeHealthCheck(id, "deleted")
+	if _, exists := m.checks[id]; exists || check.index != -1 || m.queue.Len() != 0 {
+		t.Errorf("check was not removed: index = %d, queued = %d", check.index, m.queue.Len())
+	}
+}
+
+func TestApplyHealthCheckChangeRemovesInactiveChecks(t *testing.T) {
+	m := newTestWorkerManager(10)
+
+	active := HealthCheck{ID: primitive.NewObjectID(), Name: "active", Interval: 60, Status: "active"}
+	inactive := HealthCheck{ID: primitive.NewObjectID(), Name: "paused", Interval: 60, Status: "inactive"}
+	deleted := HealthCheck{ID: primitive.NewObjectID(), Name: "deleted", Interval: 60, Status: "active"}
+	for _, hc := range []HealthCheck{active, inactive, deleted} {
+		m.applyHealthCheck(hc, schedulerTestNow)
+	}
+
+	var change healthCheckChange
+	change.OperationType = "update"
+	change.DocumentKey.ID = inactive.ID
+	change.FullDocument = &inactive
+	m.applyHealthCheckChange(context.Background(), change)
+
+	// Without a full document the check was deleted before the lookup
+	change.DocumentKey.ID = deleted.ID
+	change.FullDocument = nil
+	m.applyHealthCheckChange(context.Background(), change)
+
+	if len(m.checks) != 1 || m.checks[active.ID.Hex()] == nil {
+		t.Errorf("checks = %v", m.checks)
+	}
+}
+
+func TestReloadHealthChecksPollsWhileStreamIsDown(t *testing.T) {
+	var output bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&output)
+
+	m := newTestWorkerManager(10)
+	m.mongoHelper = NewMongoHelper(newTestDatabase(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	m.reloadHealthChecks(ctx)
+
+	logs := output.String()
+	if !strings.Contains(logs, "polling and reconnecting in "+changeStreamRetryWait.String()) {
+		t.Errorf("stream failure was not logged: %s", logs)
+	}
+	if !strings.Contains(logs, "Failed to load health checks") {
+		t.Errorf("health checks were not polled: %s", logs)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const ChannelWebhook = "webhook"
+
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+func NewWebhookNotifier(client *http.Client) *WebhookNotifier {
+	return &WebhookNotifier{client: client}
+}
+
+func (n *WebhookNotifier) Type() string {
+	return ChannelWebhook
+}
+
+func (n *WebhookNotifier) Send(ctx context.Context, channel NotificationChannel, notification Notification) error {
+	if channel.URL == "" {
+		return fmt.Errorf("webhook url is required")
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("error encoding webhook payload: %w", err)
+	}
+
+	headers := make(map[string]string, len(channel.Headers)+3)
+	for key, value := range channel.Headers {
+		headers[key] = value
+	}
+	headers["X-HST-Event"] = notification.Event
+
+	if channel.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers["X-HST-Timestamp"] = timestamp
+		headers["X-HST-Signature"] = "sha256=" + SignWebhookPayload(channel.Secret, timestamp, payload)
+	}
+
+	return postJSON(ctx, n.client, channel.URL, payload, headers)
+}
+
+// SignWebhookPayload computes HMAC-SHA256 over "<timestamp>.<body>" so
+// receivers can reject replayed deliveries.
+func SignWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
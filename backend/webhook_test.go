@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestDatabase returns a database that can't be reached; writes fail
+// fast and are only logged by the code under test.
+func newTestDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	client, err := mongo.Connect(context.Background(), options.Client().
+		ApplyURI("mongodb://127.0.0.1:1").
+		SetServerSelectionTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	return client.Database("hst_test")
+}
+
+// newTestDispatcher returns a dispatcher without a reachable database, so
+// delivery records are only checked through the value deliver returns.
+func newTestDispatcher(t *testing.T) *NotificationDispatcher {
+	t.Helper()
+
+	d := NewNotificationDispatcher(newTestDatabase(t))
+	d.backoff = time.Millisecond
+	return d
+}
+
+func testNotification(to string) Notification {
+	from := StateUp
+	if to == StateUp {
+		from = StateDown
+	}
+	return Notification{
+		Event:     EventStateChange,
+		CheckID:   "65f000000000000000000001",
+		CheckName: "api",
+		URL:       "https://api.example.com/health",
+		From:      from,
+		To:        to,
+		Reason:    "3 consecutive failures",
+		Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received *http.Request
+		body     []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	channel := NotificationChannel{
+		Type:    ChannelWebhook,
+		URL:     server.URL + "/hook",
+		Secret:  "s3cret",
+		Headers: map[string]string{"X-Team": "platform"},
+	}
+	notification := testNotification(StateDown)
+
+	before := time.Now().Unix()
+	if err := NewWebhookNotifier(server.Client()).Send(context.Background(), channel, notification); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if received.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("content type = %q", received.Header.Get("Content-Type"))
+	}
+	if received.Header.Get("X-HST-Event") != EventStateChange {
+		t.Errorf("event header = %q", received.Header.Get("X-HST-Event"))
+	}
+	if received.Header.Get("X-Team") != "platform" {
+		t.Errorf("custom header = %q", received.Header.Get("X-Team"))
+	}
+
+	timestamp := received.Header.Get("X-HST-Timestamp")
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || sent < before || sent > time.Now().Unix() {
+		t.Errorf("timestamp header = %q", timestamp)
+	}
+
+	want := "sha256=" + SignWebhookPayload(channel.Secret, timestamp, body)
+	if got := received.Header.Get("X-HST-Signature"); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if SignWebhookPayload("other", timestamp, body) == SignWebhookPayload(channel.Secret, timestamp, body) {
+		t.Error("signature does not depend on the secret")
+	}
+
+	var decoded Notification
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if decoded.CheckName != "api" || decoded.To != StateDown || decoded.Reason != notification.Reason {
+		t.Errorf("payload = %+v", decoded)
+	}
+}
+
+func TestWebhookWithoutSecretIsUnsigned(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	channel := NotificationChannel{Type: ChannelWebhook, URL: server.URL}
+	if err := NewWebhookNotifier(server.Client()).Send(context.Background(), channel, testNotification(StateDown)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if headers.Get("X-HST-Signature") != "" || headers.Get("X-HST-Timestamp") != "" {
+		t.Errorf("unexpected signature headers: %v", headers)
+	}
+}
+
+func TestWebhookDeliveryRetriesServerErrors(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		calls      int
+		signatures []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		signatures = append(signatures, r.Header.Get("X-HST-Signature"))
+		if calls < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t)
+	channel := NotificationChannel{Type: ChannelWebhook, URL: server.URL + "/hook?token=abc", Secret: "s3cret"}
+
+	delivery := d.deliver(context.Background(), channel, testNotification(StateDown), 5)
+
+	if !delivery.Success || delivery.Attempts != 3 || delivery.Error != nil {
+		t.Fatalf("delivery = %+v", delivery)
+	}
+	if delivery.Channel != ChannelWebhook || delivery.Event != EventStateChange || delivery.State != StateDown || delivery.CheckName != "api" {
+		t.Errorf("delivery = %+v", delivery)
+	}
+	if delivery.Target != server.URL {
+		t.Errorf("target = %q, want the url without path and query", delivery.Target)
+	}
+	if delivery.Timestamp.IsZero() {
+		t.Error("delivery has no timestamp")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, signature := range signatures {
+		if signature == "" {
+			t.Errorf("attempt %d was not signed", i+1)
+		}
+	}
+}
+
+func TestWebhookDeliveryGivesUp(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantAttempts int
+	}{
+		{"server error exhausts attempts", http.StatusBadGateway, 3},
+		{"client error is final", http.StatusBadRequest, 1},
+		{"rate limit is retried", http.StatusTooManyRequests, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				calls int
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				http.Error(w, "nope", tt.status)
+			}))
+			defer server.Close()
+
+			d := newTestDispatcher(t)
+			channel := NotificationChannel{Type: ChannelWebhook, URL: server.URL}
+
+			delivery := d.deliver(context.Background(), channel, testNotification(StateDown), 3)
+
+			if delivery.Success || delivery.Error == nil {
+				t.Fatalf("delivery = %+v", delivery)
+			}
+			if delivery.Attempts != tt.wantAttempts || delivery.StatusCode != tt.status {
+				t.Errorf("attempts = %d, status = %d", delivery.Attempts, delivery.StatusCode)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if calls != tt.wantAttempts {
+				t.Errorf("server saw %d calls, want %d", calls, tt.wantAttempts)
+			}
+		})
+	}
+}
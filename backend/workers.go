@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	OverlapSkip  = "skip"
+	OverlapQueue = "queue"
+	OverlapAllow = "allow"
+)
+
+const (
+	defaultHealthCheckConcurrency = 50
+	maxQueuedRuns                 = 1000
+)
+
+type healthCheckJob struct {
+	check       *ScheduledCheck
+	healthCheck HealthCheck // configuration at dispatch time
+}
+
+type CheckRunStats struct {
+	Name          string     `json:"name"`
+	Overlap       string     `json:"overlap"`
+	InFlight      int        `json:"inFlight"`
+	Pending       bool       `json:"pending"`
+	Runs          int64      `json:"runs"`
+	Skipped       int64      `json:"skipped"`
+	LastSkippedAt *time.Time `json:"lastSkippedAt,omitempty"`
+	NextRun       *time.Time `json:"nextRun,omitempty"`
+}
+
+type RunStats struct {
+	Workers  int             `json:"workers"`
+	Queued   int             `json:"queued"`
+	Capacity int             `json:"capacity"`
+	Checks   []CheckRunStats `json:"checks"`
+}
+
+func (hc HealthCheck) overlapPolicy() string {
+	switch hc.Overlap {
+	case OverlapQueue, OverlapAllow:
+		return hc.Overlap
+	default:
+		return OverlapSkip
+	}
+}
+
+func (m *HealthCheckManager) startWorkers(ctx context.Context) {
+	for i := 0; i < m.workers; i++ {
+		go m.worker(ctx)
+	}
+	log.Printf("Started %d health check workers", m.workers)
+}
+
+func (m *HealthCheckManager) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-m.jobs:
+			m.executeHealthCheck(ctx, job.healthCheck)
+			m.finishRun(job.check)
+		}
+	}
+}
+
+// dispatch hands a due check to the worker pool, applying its overlap
+// policy when the previous run has not finished yet. Callers hold m.mu.
+func (m *HealthCheckManager) dispatch(check *ScheduledCheck, now time.Time) {
+	if check.inFlight > 0 {
+		switch check.HealthCheck.overlapPolicy() {
+		case OverlapAllow:
+		case OverlapQueue:
+			if !check.pending {
+				check.pending = true
+				return
+			}
+			m.skipRun(check, now, "a run is already queued behind the one in progress")
+			return
+		default:
+			m.skipRun(check, now, "previous run still in progress")
+			return
+		}
+	}
+
+	m.enqueue(check, now)
+}
+
+// enqueue never blocks; the scheduler and workers must keep moving when
+// the pool is saturated. Callers hold m.mu.
+func (m *HealthCheckManager) enqueue(check *ScheduledCheck, now time.Time) {
+	select {
+	case m.jobs <- healthCheckJob{check: check, healthCheck: check.HealthCheck}:
+		check.inFlight++
+		check.runs++
+		log.Printf("Executing health check: %s", check.HealthCheck.Name)
+	default:
+		m.skipRun(check, now, "worker pool queue is full")
+	}
+}
+
+func (m *HealthCheckManager) finishRun(check *ScheduledCheck) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	check.inFlight--
+	if check.pending && check.inFlight == 0 {
+		check.pending = false
+		m.enqueue(check, time.Now())
+	}
+}
+
+func (m *HealthCheckManager) skipRun(check *ScheduledCheck, now time.Time, reason string) {
+	check.skipped++
+	check.lastSkipped = now
+	log.Printf("[%s] Skipped run: %s (%d skipped so far)", check.HealthCheck.Name, reason, check.skipped)
+}
+
+func (m *HealthCheckManager) runStats() RunStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := RunStats{
+		Workers:  m.workers,
+		Queued:   len(m.jobs),
+		Capacity: cap(m.jobs),
+		Checks:   make([]CheckRunStats, 0, len(m.checks)),
+	}
+
+	for _, check := range m.checks {
+		entry := CheckRunStats{
+			Name:     check.HealthCheck.Name,
+			Overlap:  check.HealthCheck.overlapPolicy(),
+			InFlight: check.inFlight,
+			Pending:  check.pending,
+			Runs:     check.runs,
+			Skipped:  check.skipped,
+		}
+		if !check.lastSkipped.IsZero() {
+			lastSkipped := check.lastSkipped
+			entry.LastSkippedAt = &lastSkipped
+		}
+		if !check.NextRun.IsZero() {
+			nextRun := check.NextRun
+			entry.NextRun = &nextRun
+		}
+		stats.Checks = append(stats.Checks, entry)
+	}
+
+	// Checks that skip the most come first
+	sort.Slice(stats.Checks, func(i, j int) bool {
+		if stats.Checks[i].Skipped != stats.Checks[j].Skipped {
+			return stats.Checks[i].Skipped > stats.Checks[j].Skipped
+		}
+		return stats.Checks[i].Name < stats.Checks[j].Name
+	})
+
+	return stats
+}
+
+func (m *HealthCheckManager) HandleRunStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		JSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	JSONResponse(w, m.runStats(), http.StatusOK)
+}
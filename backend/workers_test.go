@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestWorkerManager(capacity int) *HealthCheckManager {
+	return &HealthCheckManager{
+		checks: make(map[string]*ScheduledCheck),
+		wake:   make(chan struct{}, 1),
+		jobs:   make(chan healthCheckJob, capacity),
+	}
+}
+
+func TestDispatchOverlapPolicies(t *testing.T) {
+	tests := []struct {
+		overlap  string
+		jobs     int
+		inFlight int
+		pending  bool
+		skipped  int64
+	}{
+		{"", 0, 1, false, 3},
+		{OverlapSkip, 0, 1, false, 3},
+		{OverlapQueue, 0, 1, true, 2},
+		{OverlapAllow, 3, 4, false, 0},
+	}
+
+	for _, test := range tests {
+		m := newTestWorkerManager(10)
+		check := newScheduledCheck(HealthCheck{Name: "api", Interval: 60, Overlap: test.overlap}, schedulerTestNow)
+		check.inFlight = 1 // a run is still in progress
+
+		for i := 0; i < 3; i++ {
+			m.dispatch(check, schedulerTestNow.Add(time.Duration(i)*time.Minute))
+		}
+
+		if len(m.jobs) != test.jobs || check.inFlight != test.inFlight || check.pending != test.pending || check.skipped != test.skipped {
+			t.Errorf("overlap %q: jobs = %d, inFlight = %d, pending = %v, skipped = %d", test.overlap, len(m.jobs), check.inFlight, check.pending, check.skipped)
+		}
+		if test.skipped > 0 && !check.lastSkipped.Equal(schedulerTestNow.Add(2*time.Minute)) {
+			t.Errorf("overlap %q: last skipped = %v", test.overlap, check.lastSkipped)
+		}
+	}
+}
+
+func TestFinishRunStartsQueuedRun(t *testing.T) {
+	m := newTestWorkerManager(10)
+	check := newScheduledCheck(HealthCheck{Name: "api", Interval: 60, Overlap: OverlapQueue}, schedulerTestNow)
+
+	m.dispatch(check, schedulerTestNow)
+	m.dispatch(check, schedulerTestNow.Add(time.Minute))
+	if len(m.jobs) != 1 || !check.pending {
+		t.Fatalf("jobs = %d, pending = %v", len(m.jobs), check.pending)
+	}
+
+	<-m.jobs
+	m.finishRun(check)
+
+	if len(m.jobs) != 1 || check.pending || check.inFlight != 1 || check.runs != 2 {
+		t.Errorf("jobs = %d, pending = %v, inFlight = %d, runs = %d", len(m.jobs), check.pending, check.inFlight, check.runs)
+	}
+}
+
+func TestDispatchSkipsWhenQueueIsFull(t *testing.T) {
+	m := newTestWorkerManager(1)
+	first := newScheduledCheck(HealthCheck{Name: "first", Interval: 60}, schedulerTestNow)
+	second := newScheduledCheck(HealthCheck{Name: "second", Interval: 60}, schedulerTestNow)
+
+	m.dispatch(first, schedulerTestNow)
+	m.dispatch(second, schedulerTestNow)
+
+	if first.runs != 1 || second.runs != 0 || second.skipped != 1 || second.inFlight != 0 {
+		t.Errorf("first = %d runs, second = %d runs, %d skipped", first.runs, second.runs, second.skipped)
+	}
+}
+
+func TestRunDueDispatchesDueChecks(t *testing.T) {
+	m := newTestWorkerManager(10)
+
+	due := newScheduledCheck(HealthCheck{Name: "due", Interval: 60}, schedulerTestNow)
+	due.setSlot(schedulerTestNow.Add(-time.Second))
+	later := newScheduledCheck(HealthCheck{Name: "later", Interval: 60}, schedulerTestNow)
+	later.setSlot(schedulerTestNow.Add(30 * time.Second))
+	for _, check := range []*ScheduledCheck{due, later} {
+		m.checks[check.HealthCheck.Name] = check
+		m.schedule(check)
+	}
+
+	wait := m.runDue(schedulerTestNow)
+
+	if len(m.jobs) != 1 || (<-m.jobs).healthCheck.Name != "due" {
+		t.Fatalf("dispatched %d jobs", len(m.jobs))
+	}
+	if wait != 30*time.Second || !due.NextRun.Equal(schedulerTestNow.Add(59*time.Second)) {
+		t.Errorf("wait = %v, due next run = %v", wait, due.NextRun)
+	}
+}
+
+func TestRunStatsOrder(t *testing.T) {
+	m := newTestWorkerManager(10)
+	m.workers = 4
+	for name, skipped := range map[string]int64{"b": 2, "a": 2, "c": 5, "d": 0} {
+		check := newScheduledCheck(HealthCheck{Name: name, Interval: 60}, schedulerTestNow)
+		check.skipped = skipped
+		if skipped > 0 {
+			check.lastSkipped = schedulerTestNow
+		}
+		m.checks[name] = check
+	}
+
+	stats := m.runStats()
+
+	var order []string
+	for _, check := range stats.Checks {
+		order = append(order, check.Name)
+	}
+	if len(order) != 4 || order[0] != "c" || order[1] != "a" || order[2] != "b" || order[3] != "d" {
+		t.Errorf("order = %v", order)
+	}
+	if stats.Workers != 4 || stats.Capacity != 10 || stats.Checks[0].LastSkippedAt == nil || stats.Checks[3].LastSkippedAt != nil {
+		t.Errorf("stats = %+v", stats)
+	}
+}
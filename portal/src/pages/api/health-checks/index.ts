@@ -91,10 +91,14 @@ export const POST: APIRoute = async ({ request }) => {
       );
     }
     
+    // The backend records updatedBy as the author of the config revision
+    const now = new Date();
     const result = await collection.insertOne({
       ...body,
       name: normalizedName,
-      createdAt: new Date(),
+      createdAt: now,
+      updatedBy: 'portal',
+      updatedAt: now,
       status: 'active'
     });
     